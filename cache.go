@@ -0,0 +1,195 @@
+package keg
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache keeps copies of files fetched from remote kegs (index, info,
+// node files) in a local directory so that they are only downloaded
+// again when changed. Every Fetch revalidates the cached copy with the
+// server (If-None-Match and If-Modified-Since) and asks for gzip
+// transfer. When the server cannot be reached (or Offline is set) the
+// cached copy is returned marked as Stale instead. Set Remote.Cache to
+// use a Cache for every Remote call.
+type Cache struct {
+	Dir     string       // directory of cached files (created if missing)
+	Client  *http.Client // RemoteClient if nil
+	Offline bool         // never contact the server, only use cached copies
+}
+
+// NewCache returns a new Cache keeping files in dir.
+func NewCache(dir string) *Cache { return &Cache{Dir: dir} }
+
+// Cached is a single response body kept by a Cache along with what is
+// needed to revalidate it.
+type Cached struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Checked      time.Time `json:"checked"` // last fetched or revalidated
+	Stale        bool      `json:"-"`       // could not be revalidated
+	Body         []byte    `json:"-"`
+}
+
+// key returns the base name of the cached files for url.
+func (c *Cache) key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Load returns the cached copy of url (marked Stale) or nil if there is
+// none without contacting the server.
+func (c *Cache) Load(url string) (*Cached, error) {
+	base := c.key(url)
+	meta, err := os.ReadFile(base + `.json`)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cached := new(Cached)
+	if err := json.Unmarshal(meta, cached); err != nil {
+		return nil, err
+	}
+	if cached.Body, err = os.ReadFile(base); err != nil {
+		return nil, err
+	}
+	cached.Stale = true
+	return cached, nil
+}
+
+// Store saves the cached copy (body first so that the metadata never
+// refers to a missing body).
+func (c *Cache) Store(cached *Cached) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	if err := writeFile(c.key(cached.URL), cached.Body); err != nil {
+		return err
+	}
+	return c.storeMeta(cached)
+}
+
+// storeMeta saves only the metadata of the cached copy (when the body
+// is unchanged).
+func (c *Cache) storeMeta(cached *Cached) error {
+	meta, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	return writeFile(c.key(cached.URL)+`.json`, meta)
+}
+
+// Fetch returns the body of url from the cache revalidating it with the
+// server first and fetching (and storing) it again only if changed.
+// The cached copy is returned with Stale set if the server cannot be
+// reached, responds with a 5xx status, or Offline is set. An ErrFetch is
+// returned for any other response without a 2xx or 304 status and an
+// ErrNotCached if there is no cached copy when one is needed.
+// Cancelling the context is always returned as an error.
+func (c *Cache) Fetch(ctx context.Context, url string) (*Cached, error) {
+	return c.fetch(ctx, c.Client, url)
+}
+
+// fetch is Fetch with the given client (RemoteClient if nil).
+func (c *Cache) fetch(ctx context.Context, client *http.Client, url string) (*Cached, error) {
+	cached, err := c.Load(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Offline {
+		if cached == nil {
+			return nil, ErrNotCached{url}
+		}
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(`Accept-Encoding`, `gzip`)
+	if cached != nil {
+		if cached.ETag != `` {
+			req.Header.Set(`If-None-Match`, cached.ETag)
+		}
+		if cached.LastModified != `` {
+			req.Header.Set(`If-Modified-Since`, cached.LastModified)
+		}
+	}
+
+	if client == nil {
+		client = RemoteClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if cached == nil || ctx.Err() != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		cached.Stale = false
+		cached.Checked = time.Now().UTC()
+		if tag := resp.Header.Get(`ETag`); tag != `` {
+			cached.ETag = tag
+		}
+		return cached, c.storeMeta(cached)
+
+	case resp.StatusCode >= 500 && cached != nil:
+		return cached, nil
+
+	case resp.StatusCode < 200 || 300 <= resp.StatusCode:
+		return nil, ErrFetch{resp}
+
+	}
+
+	body, err := readBody(resp)
+	if err != nil {
+		if cached == nil || ctx.Err() != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+
+	fresh := &Cached{
+		URL:          url,
+		ETag:         resp.Header.Get(`ETag`),
+		LastModified: resp.Header.Get(`Last-Modified`),
+		Checked:      time.Now().UTC(),
+		Body:         body,
+	}
+	return fresh, c.Store(fresh)
+}
+
+// readBody reads the whole body of the response decompressing it if
+// gzip encoded.
+func readBody(resp *http.Response) ([]byte, error) {
+	if !strings.EqualFold(resp.Header.Get(`Content-Encoding`), `gzip`) {
+		return io.ReadAll(resp.Body)
+	}
+	z, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	return io.ReadAll(z)
+}
@@ -0,0 +1,130 @@
+package keg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ChangesFileName = `changes.md`
+	NodesFileName   = `nodes.tsv`
+	ChangesTitle    = `Last Changes Index` // for display only (see serve)
+)
+
+// DexEntry matches a single dex/changes.md entry line capturing the
+// Changed time, Title, and ID (in that order). Both relative (../1)
+// and rooted (/1) node links are matched.
+var DexEntry = regexp.MustCompile(
+	`^\* (` + IsoTimeExpStr + `) \[(.+)\]\((?:\.\.)?/(\d+)\)\s*$`)
+
+// sorted returns a copy of the Index (sharing the same Node references)
+// so that it can be sorted without changing the order of the original.
+func (dex *Index) sorted() *Index {
+	c := NewIndex()
+	c.Nodes = append(c.Nodes, dex.Nodes...)
+	return c
+}
+
+// WriteChanges writes the KEGML dex/changes.md content for the Index
+// to w: a single bulleted list (without any title, matching existing
+// kegs) with one entry for every node in reverse chronological order
+// (see SortByChanges):
+//
+//     * 2022-11-26 19:33:24Z [Sample content node](../1)
+//
+// The order of the Nodes slice of the Index is not changed.
+func (dex *Index) WriteChanges(w io.Writer) error {
+	c := dex.sorted()
+	c.SortByID()
+	c.SortByChanges()
+	for _, n := range c.Nodes {
+		_, err := fmt.Fprintf(w, "* %v [%v](../%v)\n",
+			n.Changed.Format(IsoTimeLayout), n.Title, n.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNodesTSV writes the dex/nodes.tsv content for the Index to w with
+// one tab-delimited line (ID, Changed, Title) for every node in
+// increasing order of ID (see SortByID). The order of the Nodes slice
+// of the Index is not changed.
+func (dex *Index) WriteNodesTSV(w io.Writer) error {
+	c := dex.sorted()
+	c.SortByID()
+	for _, n := range c.Nodes {
+		line := strings.Join([]string{
+			n.ID, n.Changed.Format(IsoTimeLayout), n.Title,
+		}, "\t")
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDex reads the index file from kegpath (see ReadIndex) and
+// regenerates the ChangesFileName and NodesFileName files within the
+// DexDirName directory (creating it if needed). Each file is replaced
+// atomically so that readers never see a partial file.
+func WriteDex(kegpath string) error {
+	dex, err := ReadIndex(kegpath)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(kegpath, DexDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var changes, nodes strings.Builder
+	dex.WriteChanges(&changes)
+	dex.WriteNodesTSV(&nodes)
+
+	if err := writeFile(filepath.Join(dir, ChangesFileName), []byte(changes.String())); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, NodesFileName), []byte(nodes.String()))
+}
+
+// ParseChanges parses the dex/changes.md content (see WriteChanges)
+// from any of the following into a new Index:
+//
+// * string
+// * []byte
+// * []rune
+// * io.Reader
+//
+// Only lines matching DexEntry are parsed into Nodes (with ID, Changed,
+// and Title) in the order they appear. Everything else (title,
+// paragraphs, other lists) is skipped. Since the changes file does not
+// contain include lists the Includes of every Node are empty.
+func ParseChanges(in any) (*Index, error) {
+	dex := NewIndex()
+
+	s := bufio.NewScanner(strings.NewReader(stringify(in)))
+
+	for s.Scan() {
+		f := DexEntry.FindStringSubmatch(s.Text())
+		if f == nil {
+			continue
+		}
+		n := new(Node)
+		n.Changed, _ = time.Parse(IsoTimeLayout, f[1])
+		n.Title = f[2]
+		n.ID = f[3]
+		n.Includes = []string{}
+		dex.Nodes = append(dex.Nodes, n)
+	}
+
+	return dex, s.Err()
+}
@@ -0,0 +1,147 @@
+package keg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DiffContext is the number of unchanged lines shown before and after
+// every change in a unified diff (see WriteDiff).
+var DiffContext = 3
+
+// WriteDiff writes the line differences between a and b to w in the
+// unified format (as produced by diff -u) with the name used for both
+// the old (a/name) and new (b/name) files. Nothing is written if a and
+// b are the same. Differences are found with a longest common
+// subsequence of lines, which is fine for files the size of a node
+// README.md but not for large files.
+func WriteDiff(w io.Writer, name string, a, b []byte) error {
+	if string(a) == string(b) {
+		return nil
+	}
+	x, y := diffLines(a), diffLines(b)
+	ops := diffOps(x, y)
+
+	if _, err := fmt.Fprintf(w, "--- a/%v\n+++ b/%v\n", name, name); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// grow the hunk until changes are more than twice the context apart
+		beg := i - DiffContext
+		if beg < 0 {
+			beg = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].op != ' ' {
+				end = j
+				continue
+			}
+			if j-end > 2*DiffContext {
+				break
+			}
+		}
+		end += DiffContext + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		var text strings.Builder
+		var alen, blen int
+		for _, o := range ops[beg:end] {
+			switch o.op {
+			case ' ':
+				alen++
+				blen++
+			case '-':
+				alen++
+			case '+':
+				blen++
+			}
+			text.WriteByte(o.op)
+			text.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				text.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		astart, bstart := ops[beg].a+1, ops[beg].b+1
+		if alen == 0 {
+			astart--
+		}
+		if blen == 0 {
+			bstart--
+		}
+		_, err := fmt.Fprintf(w, "@@ -%v,%v +%v,%v @@\n%v",
+			astart, alen, bstart, blen, text.String())
+		if err != nil {
+			return err
+		}
+		i = end
+	}
+
+	return nil
+}
+
+// diffOp is a single line of a unified diff with the index of the line
+// within the old (a) and new (b) lines at the point it occurs.
+type diffOp struct {
+	op   byte // ' ', '-', or '+'
+	line string
+	a, b int
+}
+
+// diffLines splits buf into lines keeping the line endings.
+func diffLines(buf []byte) []string {
+	lines := strings.SplitAfter(string(buf), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOps returns the operations to turn x into y from the longest
+// common subsequence of their lines.
+func diffOps(x, y []string) []diffOp {
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, diffOp{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', x[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', y[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
@@ -3,6 +3,10 @@ package keg
 import (
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ErrFetch struct {
@@ -10,3 +14,49 @@ type ErrFetch struct {
 }
 
 func (e ErrFetch) Error() string { return fmt.Sprintf(_Fetch, e.Resp.Status) }
+
+// ErrScan contains every error encountered while reading content node
+// directories keyed to the ID (directory name) of the node that failed.
+// See ScanIndex.
+type ErrScan map[string]error
+
+func (e ErrScan) Error() string {
+	ids := keys(e)
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	msgs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		msgs = append(msgs, fmt.Sprintf(_ScanNode, id, e[id]))
+	}
+	return fmt.Sprintf(_Scan, len(ids), strings.Join(msgs, "; "))
+}
+
+// ErrCycle contains the IDs of nodes that include each other in the
+// order they are included ending with the first one repeated. See
+// Keg.Expand.
+type ErrCycle []string
+
+func (e ErrCycle) Error() string { return fmt.Sprintf(_Cycle, strings.Join(e, " -> ")) }
+
+// ErrNotCached is returned by a Cache that is Offline when nothing has
+// been cached for the URL.
+type ErrNotCached struct {
+	URL string
+}
+
+func (e ErrNotCached) Error() string { return fmt.Sprintf(_NotCached, e.URL) }
+
+// ErrStale is returned by a Remote with a Cache along with the cached
+// data when it could not be revalidated (see Cache.Fetch). The data is
+// still usable but may be out of date.
+type ErrStale struct {
+	URL     string
+	Checked time.Time // when last fetched or revalidated
+}
+
+func (e ErrStale) Error() string {
+	return fmt.Sprintf(_Stale, e.URL, e.Checked.Format(IsoTimeLayout))
+}
@@ -1,14 +1,40 @@
 package keg_test
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/rwxrob/keg"
 )
 
+// tempKeg creates a keg directory (with a keg info file) within a new
+// temporary directory and a content node for every pair of ID and
+// README.md body (see writeNode) returning its path. Remove when done.
+func tempKeg(nodes ...string) string {
+	dir, _ := os.MkdirTemp("", "keg")
+	os.WriteFile(filepath.Join(dir, "keg"), []byte("updated: 2022-11-26 19:33:24Z\n"), 0600)
+	for i := 0; i+1 < len(nodes); i += 2 {
+		writeNode(dir, nodes[i], nodes[i+1])
+	}
+	return dir
+}
+
+// writeNode writes the README.md body of the content node with the
+// given ID within the keg directory creating the node directory if
+// needed.
+func writeNode(dir, id, body string) {
+	os.Mkdir(filepath.Join(dir, id), 0700)
+	os.WriteFile(filepath.Join(dir, id, "README.md"), []byte(body), 0600)
+}
+
 func ExampleNewNode() {
 
 	n := keg.NewNode()
@@ -466,3 +492,1029 @@ func ExampleParseTitle_gt_70() {
 	// "Some kinda title that is a bit more than 70 runes long, but why would "
 
 }
+
+func ExampleScanIndex() {
+
+	dex, err := keg.ScanIndex(`testdata/samplekeg`)
+	if err != nil {
+		fmt.Println(err)
+	}
+	dex.MapIDs()
+	fmt.Println(len(dex.Nodes))
+	fmt.Println(dex.IDs["0"].Title)
+	fmt.Println(dex.IDs["12"].Title)
+
+	// Output:
+	// 13
+	// Sorry, planned but not yet available
+	// Some title for 12
+}
+
+func ExampleScanIndex_fail() {
+
+	dir := tempKeg("2", "# Two\n")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "1"), 0700) // no README.md
+
+	dex, err := keg.ScanIndex(dir)
+	fmt.Println(len(dex.Nodes), dex.Nodes[0].ID)
+	fmt.Println(strings.Replace(err.Error(), dir, "DIR", 1))
+
+	// Output:
+	// 1 2
+	// failed to read 1 node(s): 1: open DIR/1/README.md: no such file or directory
+}
+
+func ExampleScanIndex_missing() {
+
+	dex, err := keg.ScanIndex(`testdata/nokeg`)
+	fmt.Println(len(dex.Nodes), err)
+
+	_, _, err = keg.UpdateIndex(`testdata/nokeg`)
+	fmt.Println(err)
+
+	// Output:
+	// 0 open testdata/nokeg: no such file or directory
+	// open testdata/nokeg: no such file or directory
+}
+
+func ExampleUpdateIndex() {
+
+	dir := tempKeg("1", "# One\n", "2", "# Two\n")
+	defer os.RemoveAll(dir)
+
+	dex, _ := keg.ScanIndex(dir)
+	keg.WriteIndex(dir, dex)
+
+	writeNode(dir, "2", "# Two Again\n")
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "2", "README.md"), later, later)
+	writeNode(dir, "3", "# Three\n")
+	os.RemoveAll(filepath.Join(dir, "1"))
+
+	dex, up, err := keg.UpdateIndex(dir)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(up.Added, up.Updated, up.Removed)
+	for _, n := range dex.Nodes {
+		fmt.Println(n.ID, n.Title)
+	}
+
+	dex, _ = keg.ReadIndex(dir)
+	fmt.Println(len(dex.Nodes))
+
+	_, up, _ = keg.UpdateIndex(dir)
+	fmt.Println(up.Changed())
+
+	// Output:
+	// [3] [2] [1]
+	// 2 Two Again
+	// 3 Three
+	// 2
+	// false
+}
+
+func ExampleReadKegInfo() {
+
+	info, err := keg.ReadKegInfo(`testdata/samplekeg`)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(info.File)
+	fmt.Println(info.Updated)
+	fmt.Println(info.Title)
+	fmt.Println(info.State)
+	fmt.Println(strings.Split(info.Summary, "\n")[4])
+	fmt.Println(info.Indexes)
+
+	// Output:
+	// testdata/samplekeg/keg
+	// 2022-11-26 19:33:24 +0000 UTC
+	// A Sample Keg
+	// living
+	//
+	// [{dex/changes.md latest changes} {dex/nodes.tsv all nodes by id}]
+}
+
+func ExampleParseKegInfo() {
+
+	info, err := keg.ParseKegInfo("title: Oops\nupdated: 2022-11-26 19:33:24Z\n")
+	fmt.Println(err)
+
+	info, err = keg.ParseKegInfo("updated: 2022-11-26 19:33:24Z\ntitle: Mine\nfoo: bar\n")
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Print(info)
+
+	// Output:
+	// updated must be the first line of keg info
+	// updated: 2022-11-26 19:33:24Z
+	//
+	// title:   Mine
+	//
+	// foo:     bar
+}
+
+func ExampleOpenKeg() {
+
+	k, err := keg.OpenKeg(`testdata/samplekeg`)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(filepath.Base(k.Path), filepath.Base(k.Dex))
+	fmt.Println(k.Info.Title)
+	fmt.Println(len(k.Nodes()), len(k.Dirs), k.Low, k.High)
+	fmt.Println(k.Node("2").Title)
+	fmt.Println(k.Node("99"))
+	body, _ := k.Body("5")
+	fmt.Printf("%q\n", body)
+
+	_, err = keg.OpenKeg(`testdata`)
+	fmt.Println(err != nil)
+
+	// Output:
+	// samplekeg dex
+	// A Sample Keg
+	// 13 13 0 12
+	// Some title for 2
+	// <nil>
+	// "# Some title for 5\n\nBlah\n"
+	// true
+}
+
+func ExampleOpenKeg_scan() {
+
+	dir := tempKeg("1", "# One\n")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "2"), 0700) // no README.md
+
+	k, err := keg.OpenKeg(dir)
+	fmt.Println(len(k.Nodes()), k.Node("1").Title, k.High)
+	fmt.Println(strings.Replace(err.Error(), dir, "DIR", 1))
+
+	// Output:
+	// 1 One 2
+	// failed to read 1 node(s): 2: open DIR/2/README.md: no such file or directory
+}
+
+func ExampleKeg_Reindex() {
+
+	dir := tempKeg("0", "# Zero\n")
+	defer os.RemoveAll(dir)
+
+	k, err := keg.OpenKeg(dir)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(len(k.Nodes()))
+
+	writeNode(dir, "1", "# One\n")
+
+	if err := k.Reindex(); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(len(k.Nodes()), k.High, k.Node("1").Title)
+
+	dex, _ := keg.ReadIndex(dir)
+	fmt.Println(len(dex.Nodes))
+
+	// Output:
+	// 1
+	// 2 1 One
+	// 2
+}
+
+func ExampleKeg_Create() {
+
+	dir := tempKeg()
+	defer os.RemoveAll(dir)
+
+	k, _ := keg.OpenKeg(dir)
+
+	n, err := k.Create(`First node`, strings.NewReader("Some body.\n"))
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(n.ID, n.Title, k.High)
+
+	n, _ = k.Create(`Second node`, nil)
+	fmt.Println(n.ID, n.Title, k.Node("1").Title)
+
+	buf, _ := os.ReadFile(filepath.Join(dir, "1", "README.md"))
+	fmt.Printf("%q\n", buf)
+
+	_, err = k.Create("", nil)
+	fmt.Println(err)
+
+	dex, _ := keg.ReadIndex(dir)
+	fmt.Println(len(dex.Nodes))
+
+	// Output:
+	// 1 First node 1
+	// 2 Second node First node
+	// "# First node\n\nSome body.\n"
+	// Node title is empty
+	// 2
+}
+
+func ExampleIndex_WriteChanges() {
+
+	dex, _ := keg.ReadIndex(`testdata/samplekeg`)
+	dex.Nodes = dex.Nodes[:5]
+	dex.WriteChanges(os.Stdout)
+
+	// Output:
+	// * 2022-11-26 19:33:24Z [Sample content node](../1)
+	// * 2022-11-22 18:05:51Z [Sorry, planned but not yet available](../0)
+	// * 2022-11-17 23:05:08Z [Some title for 5](../3)
+	// * 2022-11-17 20:37:57Z [Some title for 2](../2)
+	// * 2022-11-17 20:37:57Z [Some title for 4](../4)
+}
+
+func ExampleIndex_WriteNodesTSV() {
+
+	dex, _ := keg.ReadIndex(`testdata/samplekeg`)
+	dex.WriteNodesTSV(os.Stdout)
+	fmt.Println(dex.Nodes[10].ID) // unchanged order
+
+	// Output:
+	// 0	2022-11-22 18:05:51Z	Sorry, planned but not yet available
+	// 1	2022-11-26 19:33:24Z	Sample content node
+	// 2	2022-11-17 20:37:57Z	Some title for 2
+	// 3	2022-11-17 23:05:08Z	Some title for 5
+	// 4	2022-11-17 20:37:57Z	Some title for 4
+	// 5	2022-11-17 20:37:57Z	Some title for 5
+	// 6	2022-11-17 23:34:10Z	Some title for 6
+	// 7	2022-11-17 20:37:57Z	Some title for 7
+	// 8	2022-11-17 20:37:57Z	Some title for 8
+	// 9	2022-11-17 20:37:57Z	Some title for 9
+	// 10	2022-11-17 20:37:57Z	Some title for 10
+	// 11	2022-11-17 20:37:57Z	Some title for 11
+	// 12	2022-11-17 20:37:57Z	Some title for 12
+	// 10
+}
+
+func ExampleWriteDex() {
+
+	dir, _ := os.MkdirTemp("", "keg")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, "kegdex"),
+		[]byte("1\t2022-11-26 19:33:24Z\tOne\n10\t2022-11-27 19:33:24Z\tTen\n"), 0600)
+
+	if err := keg.WriteDex(dir); err != nil {
+		fmt.Println(err)
+	}
+	buf, _ := os.ReadFile(filepath.Join(dir, "dex", "changes.md"))
+	fmt.Print(string(buf))
+	buf, _ = os.ReadFile(filepath.Join(dir, "dex", "nodes.tsv"))
+	fmt.Print(string(buf))
+
+	// Output:
+	// * 2022-11-27 19:33:24Z [Ten](../10)
+	// * 2022-11-26 19:33:24Z [One](../1)
+	// 1	2022-11-26 19:33:24Z	One
+	// 10	2022-11-27 19:33:24Z	Ten
+}
+
+func ExampleParseChanges() {
+
+	buf, _ := os.ReadFile(`testdata/samplekeg/dex/changes.md`)
+	dex, err := keg.ParseChanges(buf)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(len(dex.Nodes))
+	fmt.Printf("%q\n", dex.Nodes[0])
+	fmt.Printf("%q\n", dex.Nodes[12])
+
+	// Output:
+	// 13
+	// "1\t2022-11-26 19:33:24Z\tSample content node\t"
+	// "2\t2022-11-17 20:37:57Z\tSome title for 2\t"
+}
+
+func ExampleIndex_UnmarshalText() {
+
+	dex, _ := keg.ParseIndex("1\t2022-11-26 19:33:24Z\tOne\t2\n2\t2022-11-26 19:33:24Z\tTwo\n")
+	dex.MapIDs()
+	dex.MapTitles()
+	dex.MapIncludes()
+	one := dex.Nodes[0]
+
+	err := dex.UnmarshalText([]byte("1\t2022-12-01 10:00:00Z\tOne Changed\t3\n\n3\t2022-12-01 10:00:00Z\tThree\n"))
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Println(len(dex.Nodes), one == dex.IDs["1"], one.Title)
+	fmt.Println(dex.Titles["One"], dex.Titles["One Changed"].ID, dex.Titles["Three"].ID)
+	fmt.Println(len(dex.Includes["2"]), dex.Includes["3"]["1"] == one)
+
+	// Output:
+	// 3 true One Changed
+	// <nil> 1 3
+	// 0 true
+}
+
+func ExampleIndex_MapLinks() {
+
+	dir := tempKeg(
+		"0", "# Zero\n",
+		"1", "# One\n\nSee [zero](../0) and [data](data.csv).\n\n* [Two](../2)\n",
+		"2", "# Two\n\nBack to [zero](../0) and [missing](../9).\n",
+	)
+	defer os.RemoveAll(dir)
+
+	dex, _ := keg.ScanIndex(dir)
+	dex.MapIDs()
+	fmt.Println(dex.IDs["1"].Includes, dex.IDs["1"].Links, dex.IDs["1"].Files)
+
+	dex.MapLinks()
+	fmt.Println(len(dex.Links["2"]), dex.Links["2"]["9"])
+	fmt.Println(len(dex.Backlinks["0"]), dex.Backlinks["0"]["2"].Title)
+	fmt.Println(len(dex.Backlinks["2"]), len(dex.Backlinks["9"]))
+
+	// Output:
+	// [2] [0 2] [data.csv]
+	// 2 <nil>
+	// 2 Two
+	// 1 1
+}
+
+func ExampleKeg_Lint() {
+
+	dir := tempKeg(
+		"0", "# Fine\n",
+		"1", "# One\n\n* a\n\n* b\n",
+		"10", "# Ten\n\n----\n\n----\n",
+	)
+	defer os.RemoveAll(dir)
+
+	k, _ := keg.OpenKeg(dir)
+	os.Mkdir(filepath.Join(dir, "2"), 0700) // no README.md
+	diags, err := k.Lint()
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+	fmt.Println(err != nil)
+
+	k, _ = keg.OpenKeg(`testdata/samplekeg`)
+	diags, _ = k.Lint()
+	fmt.Println(diags)
+
+	// Output:
+	// 1/README.md:5:1: list must not follow list
+	// 10/README.md:5:1: separator must not follow separator
+	// true
+	// []
+}
+
+func ExampleKeg_Fix() {
+
+	dir := tempKeg(
+		"0", "# Fine\n",
+		"1", "# One\n* a\n- b\n",
+	)
+	defer os.RemoveAll(dir)
+
+	k, _ := keg.OpenKeg(dir)
+	diags, err := k.Fix(true, os.Stdout)
+	fmt.Println(diags, err)
+	buf, _ := os.ReadFile(filepath.Join(dir, "1", "README.md"))
+	fmt.Printf("%q\n", buf)
+
+	diags, err = k.Fix(false, nil)
+	fmt.Println(len(diags), err)
+	buf, _ = os.ReadFile(filepath.Join(dir, "1", "README.md"))
+	fmt.Printf("%q\n", buf)
+	fmt.Println(k.Node("1").Title)
+
+	// Output:
+	// --- a/1/README.md
+	// +++ b/1/README.md
+	// @@ -1,3 +1,3 @@
+	//  # One
+	//  * a
+	// -- b
+	// +* b
+	// [1/README.md:3:1: changed bullet "-" to "*"] <nil>
+	// "# One\n* a\n- b\n"
+	// 1 <nil>
+	// "# One\n* a\n* b\n"
+	// One
+}
+
+func ExampleWriteDiff() {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	keg.WriteDiff(os.Stdout, "file", []byte(a), []byte(b))
+
+	// Output:
+	// --- a/file
+	// +++ b/file
+	// @@ -1,4 +1,4 @@
+	// -1
+	// +one
+	//  2
+	//  3
+	//  4
+	// @@ -10,3 +10,4 @@
+	//  10
+	//  11
+	//  12
+	// +13
+	// \ No newline at end of file
+}
+
+func ExampleKeg_Expand() {
+
+	dir := tempKeg(
+		"1", "# Handbook\n\nAll about it[^1].\n\n"+
+			"* [Getting Started](../2)\n* [Whatever](../3?T)\n\n"+
+			"* [A lede](../4?L)\n* [Ignored](../5?0)\n\n[^1]: The root note.",
+		"2", "# Two\n\nStart here[^1].\n\n* [Deeper](../5)\n\n[^1]: A note from two.",
+		"3", "# The *Third* Title\n\nThree.",
+		"4", "# Four\n\nFour.",
+		"5", "# Five\n\nFive.",
+		"6", "# Six\n\n* [Seven](../7)",
+		"7", "# Seven\n\n* [Six](../6?0)",
+	)
+	defer os.RemoveAll(dir)
+
+	k, _ := keg.OpenKeg(dir)
+	buf, err := k.Expand("1")
+	fmt.Println(err)
+	fmt.Print(string(buf))
+
+	_, err = k.Expand("6")
+	fmt.Println(err)
+
+	// Output:
+	// <nil>
+	// # Handbook
+	//
+	// All about it[^1].
+	//
+	// ## Getting Started
+	//
+	// Start here[^2-1].
+	//
+	// ### Deeper
+	//
+	// Five.
+	//
+	// ## The *Third* Title
+	//
+	// Three.
+	//
+	// ***A lede***
+	//
+	// Four.
+	//
+	// Five.
+	//
+	// [^2-1]: A note from two.
+	// [^1]: The root note.
+	// include cycle: 6 -> 7 -> 6
+}
+
+func ExampleIndex_Cycles() {
+
+	dex, _ := keg.ParseIndex(`1	2022-11-26 19:33:24Z	One	2,3
+2	2022-11-26 19:33:24Z	Two	3
+3	2022-11-26 19:33:24Z	Three	1
+4	2022-11-26 19:33:24Z	Four	4
+5	2022-11-26 19:33:24Z	Five	9
+`)
+
+	fmt.Println(dex.Cycles())
+
+	// Output:
+	// [[1 2 3] [1 3] [4]]
+}
+
+func ExampleIndex_Reachable() {
+
+	dex, _ := keg.ParseIndex(`1	2022-11-26 19:33:24Z	One	2,4
+2	2022-11-26 19:33:24Z	Two	3
+3	2022-11-26 19:33:24Z	Three	1
+4	2022-11-26 19:33:24Z	Four	3,9
+5	2022-11-26 19:33:24Z	Five
+`)
+
+	ids := []string{}
+	for _, n := range dex.Reachable("1") {
+		ids = append(ids, n.ID)
+	}
+	fmt.Println(ids)
+	fmt.Println(dex.Reachable("9"))
+
+	// Output:
+	// [1 2 3 4]
+	// []
+}
+
+func ExampleIndex_TopoOrder() {
+
+	dex, _ := keg.ParseIndex(`1	2022-11-26 19:33:24Z	One	2,4
+2	2022-11-26 19:33:24Z	Two	3
+3	2022-11-26 19:33:24Z	Three
+4	2022-11-26 19:33:24Z	Four	3,5
+5	2022-11-26 19:33:24Z	Five	6
+6	2022-11-26 19:33:24Z	Six	4
+`)
+
+	nodes, err := dex.TopoOrder("2")
+	for _, n := range nodes {
+		fmt.Print(n.ID, " ")
+	}
+	fmt.Println(err)
+
+	nodes, err = dex.TopoOrder("1")
+	fmt.Println(len(nodes), err)
+
+	// Output:
+	// 3 2 <nil>
+	// 2 include cycle: 4 -> 5 -> 6 -> 4
+}
+
+func ExampleKeg_Expand_limits() {
+
+	dir := tempKeg(
+		"1", "# One\n\n* [Two](../2)",
+		"2", "# Two\n\n* [Three](../3)",
+		"3", "# Three\n\n* [Four](../4)",
+		"4", "# Four\n\n* [Five](../5)",
+		"5", "# Five\n\n* [Six](../6)",
+		"6", "# Six\n\n* [Seven](../7)",
+		"7", "# Seven\n\nDeep.",
+		"8", "# Eight\n\n* [Bad](../7?X)",
+	)
+	defer os.RemoveAll(dir)
+
+	k, _ := keg.OpenKeg(dir)
+	buf, err := k.Expand("1")
+	fmt.Println(err)
+	fmt.Print(string(buf))
+
+	_, err = k.Expand("8")
+	fmt.Println(err)
+
+	// Output:
+	// <nil>
+	// # One
+	//
+	// ## Two
+	//
+	// ### Three
+	//
+	// #### Four
+	//
+	// ##### Five
+	//
+	// ###### Six
+	//
+	// ###### Seven
+	//
+	// Deep.
+	// unknown include query code "X" in node 8
+}
+
+func ExampleIndex_WriteDOT() {
+
+	dir := tempKeg(
+		"0", "# Sorry, \"planned\"\n",
+		"1", "# One\n\n* [Two](../2)\n* [Ten](../10)\n",
+		"2", "# Two\n\nSee [zero](../0).\n",
+		"10", "# Ten\n",
+	)
+	defer os.RemoveAll(dir)
+
+	dex, _ := keg.ScanIndex(dir)
+	dex.WriteDOT(os.Stdout, &keg.GraphOptions{StyleZero: true})
+	dex.WriteDOT(os.Stdout, &keg.GraphOptions{Root: "2"})
+
+	// Output:
+	// digraph keg {
+	//   "0" [label="Sorry, \"planned\"" shape=box style=filled fillcolor=lightgray];
+	//   "1" [label="One"];
+	//   "2" [label="Two"];
+	//   "10" [label="Ten"];
+	//   "1" -> "2";
+	//   "1" -> "10";
+	//   "2" -> "0" [style=dashed];
+	// }
+	// digraph keg {
+	//   "2" [label="Two"];
+	// }
+}
+
+func ExampleIndex_WriteMermaid() {
+
+	dex, _ := keg.ParseIndex(`0	2022-11-26 19:33:24Z	Zero
+1	2022-11-26 19:33:24Z	One	2,0
+2	2022-11-26 19:33:24Z	Two "2"
+`)
+	dex.WriteMermaid(os.Stdout, &keg.GraphOptions{StyleZero: true})
+
+	// Output:
+	// graph TD
+	//   n0["Zero"]
+	//   n1["One"]
+	//   n2["Two #quot;2#quot;"]
+	//   n1 --> n2
+	//   n1 --> n0
+	//   classDef zero fill:#ddd,stroke:#333,stroke-width:2px
+	//   class n0 zero
+}
+
+func ExampleIndex_WriteGraphJSON() {
+
+	dex, _ := keg.ParseIndex(`0	2022-11-26 19:33:24Z	Zero
+1	2022-11-26 19:33:24Z	One	2
+2	2022-11-26 19:33:24Z	Two
+3	2022-11-26 19:33:24Z	Three	0
+`)
+	dex.WriteGraphJSON(os.Stdout, &keg.GraphOptions{Root: "1", StyleZero: true})
+	dex.WriteGraphJSON(os.Stdout, &keg.GraphOptions{Root: "3", StyleZero: true})
+
+	// Output:
+	// {"nodes":[{"id":"1","title":"One"},{"id":"2","title":"Two"}],"edges":[{"from":"1","to":"2","type":"include"}]}
+	// {"nodes":[{"id":"0","title":"Zero","zero":true},{"id":"3","title":"Three"}],"edges":[{"from":"3","to":"0","type":"include"}]}
+}
+
+func ExampleSearchIndex_Search() {
+
+	s := keg.NewSearchIndex()
+	s.Add("1", "# Go Routines\n\nGoroutines are **lightweight** threads.")
+	s.Add("2", "# Threads\n\nOperating system threads are heavy. See [go](../1).")
+	s.Add("3", "# Markup\n\nThe `**` token is not indexed, nor is [this link](../999).")
+
+	show := func(query string) {
+		results, err := s.Search(query)
+		ids := []string{}
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		fmt.Println(query, ids, err)
+	}
+
+	show("threads")
+	show("go")
+	show("gorout*")
+	show(`"system threads"`)
+	show(`"threads system"`)
+	show("threads heavy")
+	show("999")
+	show(`"unclosed`)
+
+	// Output:
+	// threads [2 1] <nil>
+	// go [1 2] <nil>
+	// gorout* [1] <nil>
+	// "system threads" [2] <nil>
+	// "threads system" [] <nil>
+	// threads heavy [2] <nil>
+	// 999 [] <nil>
+	// "unclosed [] unclosed phrase in query: "unclosed
+}
+
+func ExampleKeg_Search() {
+
+	dir := tempKeg(
+		"1", "# One\n\nThe first *node*.",
+		"2", "# Two\n\nThe second node.",
+	)
+	defer os.RemoveAll(dir)
+
+	k, _ := keg.OpenKeg(dir)
+	results, err := k.Search("second")
+	fmt.Println(len(results), results[0].ID, err)
+
+	s, err := keg.ReadSearch(dir)
+	fmt.Println(s.Lengths, len(s.Terms["node"]), err)
+
+	// changing the index file (Create, Reindex, ...) rebuilds it
+	k.Create(`Three`, strings.NewReader("A second coming."))
+	results, err = k.Search("second")
+	fmt.Println(len(results), err)
+
+	// so does editing a node without updating the index
+	writeNode(dir, "1", "# One\n\nThe first edited node.")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "1", "README.md"), later, later)
+	results, err = k.Search("edited")
+	fmt.Println(len(results), results[0].ID, err)
+
+	// Output:
+	// 1 2 <nil>
+	// map[1:4 2:4] 2 <nil>
+	// 2 <nil>
+	// 1 1 <nil>
+}
+
+func ExampleIndex_Query() {
+
+	dex, _ := keg.ParseIndex(`0	2022-10-01 10:00:00Z	Sorry, planned but not yet available
+1	2022-11-26 19:33:24Z	Sample content node	2,3
+2	2022-11-01 08:00:00Z	Another Node	3
+3	2022-11-01 23:59:59Z	Yet another	0
+10	2022-12-25 00:00:00Z	Ten (node)
+20	2022-11-15 12:00:00Z	Twenty
+`)
+
+	show := func(expr string) {
+		nodes, err := dex.Query(expr)
+		ids := []string{}
+		for _, n := range nodes {
+			ids = append(ids, n.ID)
+		}
+		fmt.Println(ids, err)
+	}
+
+	show(``)
+	show(`title~"^[ST]"`)
+	show(`title:NODE`)
+	show(`title="Ten (node)"`)
+	show(`changed>=2022-11-01`)
+	show(`changed:2022-11-01`)
+	show(`changed<=2022-11-01`)
+	show(`changed>"2022-11-01 08:00:00Z"`)
+	show(`includes:3`)
+	show(`includedby:1`)
+	show(`id:2..10`)
+	show(`id:10..`)
+	show(`id>=3 not id:10`)
+	show(`title:another and includes:3 or id:0`)
+	show(`title:another and (includes:3 or id:0)`)
+	show(`not (includedby:1 or includedby:2) order by changed desc limit 3`)
+	show(`order by title`)
+	show(`order by id desc limit 2`)
+	show(`nope:1`)
+	show(`title>x`)
+	show(`id:x`)
+	show(`title~"("`)
+	show(`title:"unclosed`)
+	show(`(id:1`)
+	show(`id:1 limit`)
+	show(`id:1 junk`)
+
+	// Output:
+	// [0 1 2 3 10 20] <nil>
+	// [0 1 10 20] <nil>
+	// [1 2 10] <nil>
+	// [10] <nil>
+	// [1 2 3 10 20] <nil>
+	// [2 3] <nil>
+	// [0 2 3] <nil>
+	// [1 3 10 20] <nil>
+	// [1 2] <nil>
+	// [2 3] <nil>
+	// [2 3 10] <nil>
+	// [10 20] <nil>
+	// [3 20] <nil>
+	// [0 2] <nil>
+	// [2] <nil>
+	// [10 1 20] <nil>
+	// [2 1 0 10 20 3] <nil>
+	// [20 10] <nil>
+	// [] query: unknown field "nope"
+	// [] query: invalid operator ">" for title
+	// [] query: invalid id value: x
+	// [] query: invalid title value: error parsing regexp: missing closing ): `(`
+	// [] query: unclosed quote at 6
+	// [] query: unexpected end
+	// [] query: unexpected end
+	// [] query: unexpected "junk" at 5
+}
+
+func ExampleIndex_FindTitles() {
+
+	dex, _ := keg.ParseIndex(`1	2022-11-26 19:33:24Z	Sample content node
+2	2022-11-26 19:33:24Z	Knowledge Exchange Graph
+3	2022-11-26 19:33:24Z	Sorry, planned but not yet available
+4	2022-11-26 19:33:24Z	Über Straße
+5	2022-11-26 19:33:24Z	Something cool
+6	2022-11-26 19:33:24Z	KegML
+`)
+
+	for _, m := range dex.FindTitles("scn", 0) {
+		fmt.Println(m.Node.ID, m.Node.Title, m.Positions)
+	}
+	fmt.Println()
+
+	for _, m := range dex.FindTitles("kg", 2) {
+		fmt.Println(m.Node.ID, m.Node.Title, m.Positions)
+	}
+	fmt.Println()
+
+	for _, m := range dex.FindTitles("üstr", 0) {
+		fmt.Println(m.Node.ID, m.Node.Title, m.Positions)
+	}
+	fmt.Println()
+
+	fmt.Println(len(dex.FindTitles("ML", 0)), len(dex.FindTitles("ml", 0)))
+	fmt.Println(dex.FindTitles("zzz", 0), dex.FindTitles("", 0))
+
+	// Output:
+	// 1 Sample content node [0 7 15]
+	//
+	// 6 KegML [0 2]
+	// 2 Knowledge Exchange Graph [0 7]
+	//
+	// 4 Über Straße [0 5 6 7]
+	//
+	// 1 3
+	// [] []
+}
+
+func ExampleRemote() {
+
+	svr := httptest.NewServer(http.FileServer(http.Dir(`testdata/samplekeg`)))
+	defer svr.Close()
+
+	ctx := context.Background()
+	r := keg.NewRemote(svr.URL + `/`)
+
+	dex, err := r.Index(ctx)
+	fmt.Println(len(dex.Nodes), dex.Nodes[1].Title, err)
+	fmt.Println(strings.TrimPrefix(dex.URL, svr.URL))
+
+	info, err := r.Info(ctx)
+	fmt.Println(info.Title, err)
+
+	buf, err := r.Node(ctx, `1`)
+	fmt.Println(strings.SplitN(string(buf), "\n", 2)[0], err)
+
+	_, err = r.File(ctx, `1`, `nope.png`)
+	var ferr keg.ErrFetch
+	fmt.Println(errors.As(err, &ferr), err)
+
+	_, err = r.File(ctx, `1`, `../keg`)
+	fmt.Println(err)
+
+	_, err = r.Node(ctx, `-1`)
+	fmt.Println(err)
+
+	// Output:
+	// 13 Sample content node <nil>
+	// /kegdex
+	// A Sample Keg <nil>
+	// # Sample content node <nil>
+	// true failed to fetch: 404 Not Found
+	// invalid node file name: "../keg"
+	// Node identifier must be positive integer
+}
+
+func ExampleRemote_timeout() {
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	svr := httptest.NewServer(slow)
+	defer svr.Close()
+
+	r := keg.NewRemote(svr.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := r.Index(ctx)
+	fmt.Println(errors.Is(err, context.DeadlineExceeded))
+
+	r.Client = &http.Client{Timeout: 50 * time.Millisecond}
+	_, err = r.Index(context.Background())
+	fmt.Println(err != nil)
+
+	// Output:
+	// true
+	// true
+}
+
+func ExampleCache() {
+
+	dir, _ := os.MkdirTemp("", "keg")
+	defer os.RemoveAll(dir)
+
+	text := "1\t2022-12-19 11:40:01Z\tSome title\t\n"
+	var sent, gzipped int
+
+	// simulate server revalidating with an ETag and sending gzip
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			tag := fmt.Sprintf(`"%x"`, len(text))
+			if r.Header.Get(`If-None-Match`) == tag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			sent++
+			w.Header().Set(`ETag`, tag)
+			if strings.Contains(r.Header.Get(`Accept-Encoding`), `gzip`) {
+				gzipped++
+				w.Header().Set(`Content-Encoding`, `gzip`)
+				z := gzip.NewWriter(w)
+				defer z.Close()
+				fmt.Fprint(z, text)
+				return
+			}
+			fmt.Fprint(w, text)
+		})
+	svr := httptest.NewServer(handler)
+
+	ctx := context.Background()
+	c := keg.NewCache(dir)
+	url := svr.URL + `/kegdex`
+
+	got, err := c.Fetch(ctx, url)
+	fmt.Printf("%q %v %v %v\n", got.Body, got.Stale, sent, err)
+
+	// only the metadata is written when not modified
+	bodies, _ := filepath.Glob(filepath.Join(dir, "*[^n]")) // not *.json
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(bodies[0], old, old)
+
+	got, err = c.Fetch(ctx, url)
+	fmt.Printf("%q %v %v %v\n", got.Body, got.Stale, sent, err)
+	info, _ := os.Stat(bodies[0])
+	fmt.Println(len(bodies), info.ModTime().Equal(old))
+
+	text += "2\t2022-12-21 12:40:01Z\tSome other title\t\n"
+	got, err = c.Fetch(ctx, url)
+	fmt.Println(len(got.Body), got.Stale, sent, gzipped, err)
+
+	// offline: still has cached copy but stale
+	svr.Close()
+	got, err = c.Fetch(ctx, url)
+	fmt.Println(len(got.Body), got.Stale, err)
+
+	c.Offline = true
+	got, err = c.Fetch(ctx, url)
+	fmt.Println(len(got.Body), got.Stale, err)
+
+	_, err = c.Fetch(ctx, svr.URL+`/keg`)
+	fmt.Println(strings.Replace(err.Error(), svr.URL, `URL`, 1))
+
+	// Output:
+	// "1\t2022-12-19 11:40:01Z\tSome title\t\n" false 1 <nil>
+	// "1\t2022-12-19 11:40:01Z\tSome title\t\n" false 1 <nil>
+	// 1 true
+	// 76 false 2 2 <nil>
+	// 76 true <nil>
+	// 76 true <nil>
+	// not cached: URL/keg
+}
+
+func ExampleRemote_cache() {
+
+	dir, _ := os.MkdirTemp("", "keg")
+	defer os.RemoveAll(dir)
+
+	svr := httptest.NewServer(http.FileServer(http.Dir(`testdata/samplekeg`)))
+
+	ctx := context.Background()
+	r := keg.NewRemote(svr.URL)
+	r.Cache = keg.NewCache(dir)
+
+	// Client is still used with a Cache (unless Cache.Client is set)
+	var requests int
+	r.Client = &http.Client{Transport: roundTripper(
+		func(req *http.Request) (*http.Response, error) {
+			requests++
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+
+	dex, err := r.Index(ctx)
+	fmt.Println(len(dex.Nodes), err)
+
+	buf, err := r.Node(ctx, `1`)
+	fmt.Println(strings.SplitN(string(buf), "\n", 2)[0], err, requests)
+
+	// the keg is gone but the cached copies remain
+	svr.Close()
+
+	var stale keg.ErrStale
+	dex, err = r.Index(ctx)
+	fmt.Println(len(dex.Nodes), errors.As(err, &stale))
+
+	buf, err = r.Node(ctx, `1`)
+	fmt.Println(strings.SplitN(string(buf), "\n", 2)[0], errors.As(err, &stale))
+	fmt.Println(strings.TrimPrefix(stale.URL, svr.URL))
+
+	_, err = r.Info(ctx)
+	fmt.Println(err != nil, errors.As(err, &stale))
+
+	// Output:
+	// 13 <nil>
+	// # Sample content node <nil> 2
+	// 13 true
+	// # Sample content node true
+	// /1/README.md
+	// true false
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
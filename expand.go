@@ -0,0 +1,173 @@
+package keg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml"
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+var includeTarget = regexp.MustCompile(`^\.\./(\d+)/?(?:\?(.*))?$`)
+
+// Expand returns the KEGML document of the node with the given ID with
+// every include list replaced by the nodes it includes (recursively)
+// according to the query code of each include link:
+//
+//     * (none) - link text becomes relative heading
+//     * T      - target title becomes relative heading
+//     * L      - link text becomes lede
+//     * 0      - just the target body (the link text is dropped)
+//
+// Headings are relative to the depth of the include: the title of the
+// node expanded is a level one heading (#), the nodes it includes are
+// level two (##), the nodes they include level three (###), and so on
+// up to level six (######) which is used for anything deeper since
+// there are no deeper headings. The titles of included nodes are never
+// kept. Footnotes of every node are moved to the end with the labels
+// of included nodes prefixed with the node ID (ex: [^3-1]) to keep them
+// unique. Include links that are not node links are kept as is. If
+// a node includes itself (directly or not) an ErrCycle is returned. An
+// unknown query code is an error. The result is KEGMLX (headings beyond
+// the title) suitable for exporting as Markdown.
+func (k *Keg) Expand(id string) ([]byte, error) {
+	x := &expander{k: k, noted: map[string]bool{}}
+	blocks, err := x.expand(id, 1, ``, ``, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(x.notes) > 0 {
+		blocks = append(blocks, strings.Join(x.notes, "\n"))
+	}
+	return []byte(strings.Join(blocks, "\n\n") + "\n"), nil
+}
+
+// expander holds the state of a single Keg.Expand.
+type expander struct {
+	k     *Keg
+	notes []string        // every footnote line
+	noted map[string]bool // IDs of nodes with footnotes already added
+}
+
+// expand returns the blocks of the node with the given ID included at
+// level (with the query and link text of the include) where stack
+// contains the IDs of every node including it.
+func (x *expander) expand(id string, level int, query, text string, stack []string) ([]string, error) {
+	for _, s := range stack {
+		if s == id {
+			return nil, ErrCycle(append(append([]string{}, stack...), id))
+		}
+	}
+	stack = append(stack, id)
+
+	body, err := x.k.Body(id)
+	if err != nil {
+		return nil, err
+	}
+	doc, _ := kegml.Parse(body)
+
+	prefix := ``
+	if level > 1 {
+		prefix = id + `-`
+	}
+
+	var blocks []string
+	depth := level
+	if depth > 6 {
+		depth = 6
+	}
+	heading := strings.Repeat(`#`, depth) + ` `
+	switch query {
+	case ``:
+		if level == 1 {
+			text = x.title(doc)
+		}
+		blocks = append(blocks, heading+text)
+	case `T`:
+		blocks = append(blocks, heading+x.title(doc))
+	case `L`:
+		blocks = append(blocks, `***`+text+`***`)
+	}
+
+	for _, n := range doc.Under {
+		switch n.T {
+
+		case ast.Title:
+
+		case ast.Footnotes:
+			if x.noted[id] {
+				continue
+			}
+			for _, note := range n.Under {
+				x.notes = append(x.notes,
+					`[^`+prefix+note.V+`]: `+relabel(note, note.XB, note.E, prefix))
+			}
+
+		case ast.Includes:
+			var kept []string
+			for _, inc := range n.Under {
+				m := includeTarget.FindStringSubmatch(inc.V)
+				if m == nil {
+					kept = append(kept, relabel(inc, inc.B, inc.E, prefix))
+					continue
+				}
+				switch m[2] {
+				case ``, `T`, `L`, `0`:
+				default:
+					return nil, fmt.Errorf(_IncludeCode, m[2], id)
+				}
+				if kept != nil {
+					blocks = append(blocks, strings.Join(kept, "\n"))
+					kept = nil
+				}
+				sub, err := x.expand(m[1], level+1, m[2], relabel(inc, inc.XB, inc.XE, prefix), stack)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, sub...)
+			}
+			if kept != nil {
+				blocks = append(blocks, strings.Join(kept, "\n"))
+			}
+
+		default:
+			blocks = append(blocks, relabel(n, n.B, n.E, prefix))
+
+		}
+	}
+	x.noted[id] = true
+
+	return blocks, nil
+}
+
+// title returns the title of the document with its inline markup.
+func (x *expander) title(doc *ast.Node) string {
+	for _, n := range doc.Under {
+		if n.T == ast.Title {
+			return strings.TrimSpace(n.Capture())
+		}
+	}
+	return ``
+}
+
+// relabel returns the runes of n from b to e with every footnote
+// reference label within prefixed (unless prefix is empty).
+func relabel(n *ast.Node, b, e int, prefix string) string {
+	if prefix == `` {
+		return string(n.R[b:e])
+	}
+	var s strings.Builder
+	at := b
+	n.Walk(func(u *ast.Node) bool {
+		if u.T != ast.FootRef || u.B < at || u.E > e {
+			return true
+		}
+		s.WriteString(string(n.R[at:u.B]))
+		s.WriteString(`[^` + prefix + u.V + `]`)
+		at = u.E
+		return false
+	})
+	s.WriteString(string(n.R[at:e]))
+	return s.String()
+}
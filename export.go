@@ -0,0 +1,163 @@
+package keg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GraphOptions change what is drawn by the graph export methods (see
+// WriteDOT, WriteMermaid, WriteGraphJSON). The zero value draws every
+// node without any special styling.
+type GraphOptions struct {
+	Root      string // only nodes reachable from Root (see Reachable)
+	StyleZero bool   // style the zero node (0) differently from others
+}
+
+// edge is a single directed edge of the graph of an Index.
+type edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"` // include or link
+}
+
+// graph returns the nodes (in ID order) and edges (in the order of
+// Includes and then Links of each node) to draw. Link edges are only
+// added for links that are not also includes. Edges to nodes not drawn
+// are always skipped.
+func (dex *Index) graph(opt *GraphOptions) ([]*Node, []edge) {
+	if opt == nil {
+		opt = new(GraphOptions)
+	}
+
+	all := dex.sorted()
+	all.SortByID()
+	nodes := all.Nodes
+	if opt.Root != "" {
+		in := map[string]bool{}
+		for _, n := range dex.Reachable(opt.Root) {
+			in[n.ID] = true
+		}
+		nodes = nodes[:0]
+		for _, n := range all.Nodes {
+			if in[n.ID] {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+
+	drawn := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		drawn[n.ID] = true
+	}
+
+	var edges []edge
+	for _, n := range nodes {
+		seen := map[string]bool{}
+		for _, to := range n.Includes {
+			if drawn[to] && !seen[to] {
+				seen[to] = true
+				edges = append(edges, edge{n.ID, to, `include`})
+			}
+		}
+		for _, to := range n.Links {
+			if drawn[to] && !seen[to] {
+				seen[to] = true
+				edges = append(edges, edge{n.ID, to, `link`})
+			}
+		}
+	}
+
+	return nodes, edges
+}
+
+// WriteDOT writes the graph of the nodes of the Index to w in the DOT
+// language of Graphviz with every node labeled by its Title and an edge
+// for every include (solid) and inline link (dashed). Inline links are
+// only known for nodes read with ReadNode (see ScanIndex). See
+// GraphOptions.
+func (dex *Index) WriteDOT(w io.Writer, opt *GraphOptions) error {
+	nodes, edges := dex.graph(opt)
+	var s strings.Builder
+	s.WriteString("digraph keg {\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&s, "  %v [label=%v", dotQuote(n.ID), dotQuote(n.Title))
+		if opt != nil && opt.StyleZero && n.ID == `0` {
+			s.WriteString(` shape=box style=filled fillcolor=lightgray`)
+		}
+		s.WriteString("];\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&s, "  %v -> %v", dotQuote(e.From), dotQuote(e.To))
+		if e.Type == `link` {
+			s.WriteString(` [style=dashed]`)
+		}
+		s.WriteString(";\n")
+	}
+	s.WriteString("}\n")
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// WriteMermaid writes the graph of the nodes of the Index to w as
+// a Mermaid flowchart (top down) with every node labeled by its Title
+// and an edge for every include (solid) and inline link (dotted). Node
+// IDs are prefixed with n (ex: n1) since Mermaid does not allow
+// numbers. See WriteDOT and GraphOptions.
+func (dex *Index) WriteMermaid(w io.Writer, opt *GraphOptions) error {
+	nodes, edges := dex.graph(opt)
+	var s strings.Builder
+	s.WriteString("graph TD\n")
+	zero := false
+	for _, n := range nodes {
+		title := strings.ReplaceAll(n.Title, `"`, `#quot;`)
+		fmt.Fprintf(&s, "  n%v[\"%v\"]\n", n.ID, title)
+		zero = zero || n.ID == `0`
+	}
+	for _, e := range edges {
+		arrow := `-->`
+		if e.Type == `link` {
+			arrow = `-.->`
+		}
+		fmt.Fprintf(&s, "  n%v %v n%v\n", e.From, arrow, e.To)
+	}
+	if zero && opt != nil && opt.StyleZero {
+		s.WriteString("  classDef zero fill:#ddd,stroke:#333,stroke-width:2px\n")
+		s.WriteString("  class n0 zero\n")
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// WriteGraphJSON writes the graph of the nodes of the Index to w as
+// a single JSON object with a nodes array (id, title, and zero if
+// styling the zero node) and an edges array (from, to, and type of
+// either include or link) followed by a line return. See WriteDOT and
+// GraphOptions.
+func (dex *Index) WriteGraphJSON(w io.Writer, opt *GraphOptions) error {
+	type jsnode struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Zero  bool   `json:"zero,omitempty"`
+	}
+	nodes, edges := dex.graph(opt)
+	g := struct {
+		Nodes []jsnode `json:"nodes"`
+		Edges []edge   `json:"edges"`
+	}{[]jsnode{}, []edge{}}
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, jsnode{
+			ID: n.ID, Title: n.Title,
+			Zero: opt != nil && opt.StyleZero && n.ID == `0`,
+		})
+	}
+	g.Edges = append(g.Edges, edges...)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(g)
+}
@@ -0,0 +1,178 @@
+package keg
+
+import (
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+// Fuzzy title scoring (see FindTitles).
+const (
+	fuzzyMatch       = 16 // every rune matched
+	fuzzyBoundary    = 8  // matched at the beginning of a word
+	fuzzyFirst       = 8  // matched first rune of title (plus boundary)
+	fuzzyCamel       = 6  // matched upper case following lower case
+	fuzzyConsecutive = 5  // matched directly after previous match
+	fuzzyGapStart    = 3  // penalty for skipping runes between matches
+	fuzzyGapExtend   = 1  // penalty for every additional rune skipped
+)
+
+// TitleMatch is a single Node with a Title matching a fuzzy query (see
+// FindTitles) along with its score and the rune offsets within the
+// title of every rune matched (for highlighting).
+type TitleMatch struct {
+	Node      *Node
+	Score     int
+	Positions []int
+}
+
+// FindTitles returns the nodes with titles containing every rune of q
+// in order (a subsequence, not necessarily together) ranked by how
+// well they match: runes matched together, at the beginning of words
+// or of the title, and with fewer runes skipped between score higher.
+// Ties go to the shorter title and then the lower ID. Matching ignores
+// case unless q contains an upper case rune (smart case) and works for
+// titles in any script since every comparison is of runes (not bytes).
+// The best scoring match of every title is found (not just the first).
+// No more than limit matches are returned unless limit is zero or less.
+// Always returns a slice even if empty.
+func (dex *Index) FindTitles(q string, limit int) []TitleMatch {
+	matches := []TitleMatch{}
+	query := []rune(q)
+	if len(query) == 0 {
+		return matches
+	}
+
+	fold := true
+	for _, r := range query {
+		if unicode.IsUpper(r) {
+			fold = false
+			break
+		}
+	}
+	if fold {
+		for i, r := range query {
+			query[i] = unicode.ToLower(r)
+		}
+	}
+
+	for _, n := range dex.Nodes {
+		score, pos, ok := fuzzy(query, []rune(n.Title), fold)
+		if ok {
+			matches = append(matches, TitleMatch{n, score, pos})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if la, lb := len([]rune(a.Node.Title)), len([]rune(b.Node.Title)); la != lb {
+			return la < lb
+		}
+		x, _ := strconv.Atoi(a.Node.ID)
+		y, _ := strconv.Atoi(b.Node.ID)
+		return x < y
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// fuzzy returns the best score of query (already folded if fold) as
+// a subsequence of title along with the positions matched or false if
+// query is not a subsequence of title at all.
+func fuzzy(query, title []rune, fold bool) (int, []int, bool) {
+	n, m := len(query), len(title)
+	if n > m {
+		return 0, nil, false
+	}
+
+	folded := title
+	if fold {
+		folded = make([]rune, m)
+		for j, r := range title {
+			folded[j] = unicode.ToLower(r)
+		}
+	}
+
+	// score[i][j] is the best score with query[i] matched at title[j]
+	// and from[i][j] the position of the match of query[i-1]
+	const none = -1 << 30
+	score := make([][]int, n)
+	from := make([][]int, n)
+	for i := range score {
+		score[i] = make([]int, m)
+		from[i] = make([]int, m)
+		for j := range score[i] {
+			score[i][j] = none
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i; j < m; j++ {
+			if folded[j] != query[i] {
+				continue
+			}
+			s := fuzzyMatch + fuzzyBonus(title, j)
+			if i == 0 {
+				score[i][j] = s
+				continue
+			}
+			best, at := none, -1
+			for k := i - 1; k < j; k++ {
+				if score[i-1][k] == none {
+					continue
+				}
+				v := score[i-1][k]
+				if k == j-1 {
+					v += fuzzyConsecutive
+				} else {
+					v -= fuzzyGapStart + fuzzyGapExtend*(j-k-2)
+				}
+				if v > best {
+					best, at = v, k
+				}
+			}
+			if at >= 0 {
+				score[i][j], from[i][j] = best+s, at
+			}
+		}
+	}
+
+	best, end := none, -1
+	for j := n - 1; j < m; j++ {
+		if score[n-1][j] > best {
+			best, end = score[n-1][j], j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	pos := make([]int, n)
+	for i := n - 1; i >= 0; i-- {
+		pos[i] = end
+		end = from[i][end]
+	}
+	return best, pos, true
+}
+
+// fuzzyBonus returns the bonus for matching the rune of title at j.
+func fuzzyBonus(title []rune, j int) int {
+	if j == 0 {
+		return fuzzyFirst + fuzzyBoundary
+	}
+	prev, cur := title[j-1], title[j]
+	word := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }
+	switch {
+	case !word(prev) && word(cur):
+		return fuzzyBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return fuzzyCamel
+	}
+	return 0
+}
@@ -0,0 +1,274 @@
+package keg
+
+import (
+	"sort"
+	"strconv"
+)
+
+// idMap returns the IDs map or a new one if MapIDs has not been called.
+func (dex *Index) idMap() map[string]*Node {
+	if dex.IDs != nil {
+		return dex.IDs
+	}
+	ids := make(map[string]*Node, len(dex.Nodes))
+	for _, n := range dex.Nodes {
+		ids[n.ID] = n
+	}
+	return ids
+}
+
+// includes returns the Includes of the node with the given ID that are
+// in ids with every duplicate removed.
+func includes(ids map[string]*Node, id string) []string {
+	n := ids[id]
+	if n == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(n.Includes))
+	inc := make([]string, 0, len(n.Includes))
+	for _, i := range n.Includes {
+		if seen[i] || ids[i] == nil {
+			continue
+		}
+		seen[i] = true
+		inc = append(inc, i)
+	}
+	return inc
+}
+
+// Cycles returns every include cycle (where a node includes itself
+// through the nodes it includes) as the IDs of the nodes in the order
+// they include each other beginning with the lowest ID (which is not
+// repeated at the end). Cycles are in order of their first ID and then
+// as found following the Includes of each node. Includes of nodes not
+// in the index are ignored. Always returns a slice even if empty.
+//
+// Cycles are found with Johnson's algorithm so that only the strongly
+// connected components (see components) that have cycles are ever
+// searched and no path is ever followed twice: the time taken is
+// linear in the number of nodes, includes, and cycles found (never
+// exponential, even for large acyclic graphs).
+func (dex *Index) Cycles() [][]string {
+	cycles := [][]string{}
+	ids := dex.idMap()
+
+	order := keys(ids)
+	sort.Slice(order, func(i, j int) bool {
+		a, _ := strconv.Atoi(order[i])
+		b, _ := strconv.Atoi(order[j])
+		return a < b
+	})
+	rank := make(map[string]int, len(order))
+	inc := make(map[string][]string, len(order))
+	for i, id := range order {
+		rank[id] = i
+		inc[id] = includes(ids, id)
+	}
+
+	// every cycle is found exactly once from its lowest node within the
+	// component (of nodes ranked after those already done) containing
+	// the lowest node of any component with a cycle
+	for s := 0; s < len(order); s++ {
+		comp := cyclic(components(order[s:], inc, rank, s), inc, rank)
+		if comp == nil {
+			break
+		}
+		start := comp[0]
+		s = rank[start]
+		in := make(map[string]bool, len(comp))
+		for _, id := range comp {
+			in[id] = true
+		}
+
+		var path []string
+		blocked := map[string]bool{}
+		waiting := map[string]map[string]bool{} // unblock with key
+		var unblock func(id string)
+		unblock = func(id string) {
+			blocked[id] = false
+			for w := range waiting[id] {
+				delete(waiting[id], w)
+				if blocked[w] {
+					unblock(w)
+				}
+			}
+		}
+		var walk func(id string) bool
+		walk = func(id string) bool {
+			found := false
+			path = append(path, id)
+			blocked[id] = true
+			for _, next := range inc[id] {
+				switch {
+				case !in[next]:
+				case next == start:
+					cycles = append(cycles, append([]string{}, path...))
+					found = true
+				case !blocked[next] && walk(next):
+					found = true
+				}
+			}
+			if found {
+				unblock(id)
+			} else {
+				for _, next := range inc[id] {
+					if !in[next] {
+						continue
+					}
+					if waiting[next] == nil {
+						waiting[next] = map[string]bool{}
+					}
+					waiting[next][id] = true
+				}
+			}
+			path = path[:len(path)-1]
+			return found
+		}
+		walk(start)
+	}
+
+	return cycles
+}
+
+// components returns the strongly connected components (Tarjan) of the
+// graph of nodes (with includes) limited to those ranked min or
+// higher. The IDs of every component are in order of rank.
+func components(nodes []string, inc map[string][]string, rank map[string]int, min int) [][]string {
+	var comps [][]string
+	index := make(map[string]int, len(nodes))
+	low := make(map[string]int, len(nodes))
+	on := map[string]bool{}
+	var stack []string
+	var count int
+	var visit func(id string)
+	visit = func(id string) {
+		index[id], low[id] = count, count
+		count++
+		stack = append(stack, id)
+		on[id] = true
+		for _, next := range inc[id] {
+			if rank[next] < min {
+				continue
+			}
+			if _, seen := index[next]; !seen {
+				visit(next)
+				if low[next] < low[id] {
+					low[id] = low[next]
+				}
+			} else if on[next] && index[next] < low[id] {
+				low[id] = index[next]
+			}
+		}
+		if low[id] != index[id] {
+			return
+		}
+		var comp []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			on[top] = false
+			comp = append(comp, top)
+			if top == id {
+				break
+			}
+		}
+		sort.Slice(comp, func(i, j int) bool { return rank[comp[i]] < rank[comp[j]] })
+		comps = append(comps, comp)
+	}
+	for _, id := range nodes {
+		if _, seen := index[id]; !seen {
+			visit(id)
+		}
+	}
+	return comps
+}
+
+// cyclic returns the component (see components) with the lowest ranked
+// first node of those having a cycle (more than one node or a node
+// including itself) or nil if none.
+func cyclic(comps [][]string, inc map[string][]string, rank map[string]int) []string {
+	var low []string
+	for _, comp := range comps {
+		if len(comp) == 1 && !includesID(inc[comp[0]], comp[0]) {
+			continue
+		}
+		if low == nil || rank[comp[0]] < rank[low[0]] {
+			low = comp
+		}
+	}
+	return low
+}
+
+// includesID returns true if id is one of the includes.
+func includesID(inc []string, id string) bool {
+	for _, i := range inc {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Reachable returns the node with the root ID and every node included
+// by it (recursively) in the order their content would appear when
+// expanded (see Keg.Expand) with every node appearing only once. Cycles
+// are ignored. Includes of nodes not in the index are skipped. Returns
+// nil if root is not in the index.
+func (dex *Index) Reachable(root string) []*Node {
+	ids := dex.idMap()
+	if ids[root] == nil {
+		return nil
+	}
+	var nodes []*Node
+	seen := map[string]bool{}
+	var walk func(id string)
+	walk = func(id string) {
+		seen[id] = true
+		nodes = append(nodes, ids[id])
+		for _, next := range includes(ids, id) {
+			if !seen[next] {
+				walk(next)
+			}
+		}
+	}
+	walk(root)
+	return nodes
+}
+
+// TopoOrder returns the node with the root ID and every node included
+// by it (recursively) in dependency order: every node comes after all
+// of the nodes it includes and the root is always last. Ties are in the
+// order of Includes. If any of them include themselves an ErrCycle is
+// returned (with the nodes as far as ordered). Includes of nodes not in
+// the index are skipped. Returns nil if root is not in the index.
+func (dex *Index) TopoOrder(root string) ([]*Node, error) {
+	ids := dex.idMap()
+	if ids[root] == nil {
+		return nil, nil
+	}
+	var nodes []*Node
+	done := map[string]bool{}
+	var path []string
+	var walk func(id string) error
+	walk = func(id string) error {
+		for i, p := range path {
+			if p == id {
+				return ErrCycle(append(append([]string{}, path[i:]...), id))
+			}
+		}
+		path = append(path, id)
+		for _, next := range includes(ids, id) {
+			if done[next] {
+				continue
+			}
+			if err := walk(next); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		done[id] = true
+		nodes = append(nodes, ids[id])
+		return nil
+	}
+	return nodes, walk(root)
+}
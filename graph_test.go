@@ -0,0 +1,50 @@
+package keg
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// lattice returns an index of a size by size grid of nodes each
+// including the node to its right and the one below it: acyclic but
+// with an exponential number of paths.
+func lattice(size int) *Index {
+	dex := NewIndex()
+	id := func(row, col int) string { return strconv.Itoa(row*size + col) }
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			n := &Node{ID: id(row, col), Includes: []string{}}
+			if col+1 < size {
+				n.Includes = append(n.Includes, id(row, col+1))
+			}
+			if row+1 < size {
+				n.Includes = append(n.Includes, id(row+1, col))
+			}
+			dex.Nodes = append(dex.Nodes, n)
+		}
+	}
+	return dex
+}
+
+func TestIndex_Cycles_lattice(t *testing.T) {
+	dex := lattice(40)
+
+	start := time.Now()
+	if cycles := dex.Cycles(); len(cycles) != 0 {
+		t.Errorf(`expected no cycles, got %v`, len(cycles))
+	}
+
+	// a single short cycle (from the last node back to the one before
+	// it) must be found just as quickly
+	last := dex.Nodes[len(dex.Nodes)-1]
+	last.Includes = append(last.Includes, strconv.Itoa(len(dex.Nodes)-2))
+	cycles := dex.Cycles()
+	if len(cycles) != 1 || len(cycles[0]) != 2 {
+		t.Errorf(`expected one cycle of two, got %v`, cycles)
+	}
+
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf(`took too long: %v`, took)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const IndexFileName = `kegdex`
@@ -21,6 +25,7 @@ const IndexFileName = `kegdex`
 //     * IDs
 //     * Titles
 //     * Includes
+//     * Links and Backlinks
 //
 // Each has a corresponding Map* method to trigger its update. Otherwise,
 // these fields remain nil.
@@ -29,25 +34,39 @@ const IndexFileName = `kegdex`
 // the Nodes slice directly.
 //
 type Index struct {
-	File     string                      // if from file system
-	URL      string                      // if from network
-	Nodes    []*Node                     // order differs (SortByID, SortByChanges)
-	IDs      map[string]*Node            // after calling MapIDs
-	Titles   map[string]*Node            // after calling MapTitles
-	Includes map[string]map[string]*Node // after calling MapIncludes
+	File      string                      // if from file system
+	URL       string                      // if from network
+	Nodes     []*Node                     // order differs (SortByID, SortByChanges)
+	IDs       map[string]*Node            // after calling MapIDs
+	Titles    map[string]*Node            // after calling MapTitles
+	Includes  map[string]map[string]*Node // after calling MapIncludes
+	Links     map[string]map[string]*Node // after calling MapLinks
+	Backlinks map[string]map[string]*Node // after calling MapLinks
 }
 
 // SortByID sorts the Nodes slice by increasing numeric value of ID.
+// IDs that are not integers are sorted after those that are.
 func (dex *Index) SortByID() {
-	sort.Slice(dex.Nodes, func(i, j int) bool {
+	sort.SliceStable(dex.Nodes, func(i, j int) bool {
+		a, aerr := strconv.Atoi(dex.Nodes[i].ID)
+		b, berr := strconv.Atoi(dex.Nodes[j].ID)
+		switch {
+		case aerr == nil && berr == nil:
+			return a < b
+		case aerr == nil:
+			return true
+		case berr == nil:
+			return false
+		}
 		return dex.Nodes[i].ID < dex.Nodes[j].ID
 	})
 }
 
 // SortByChanges sorts the Nodes slice by most recent change (Changed in
-// reverse chronological order).
+// reverse chronological order). Nodes with the same Changed time keep
+// their relative order.
 func (dex *Index) SortByChanges() {
-	sort.Slice(dex.Nodes, func(i, j int) bool {
+	sort.SliceStable(dex.Nodes, func(i, j int) bool {
 		return dex.Nodes[i].Changed.After(dex.Nodes[j].Changed)
 	})
 }
@@ -86,13 +105,53 @@ func (dex *Index) MapTitles() {
 func (dex *Index) MapIncludes() {
 	dex.Includes = make(map[string]map[string]*Node, len(dex.Nodes))
 	for _, n := range dex.Nodes {
-		dex.Includes[n.ID] = map[string]*Node{}
+		if dex.Includes[n.ID] == nil {
+			dex.Includes[n.ID] = map[string]*Node{}
+		}
 		for _, in := range n.Includes {
+			if dex.Includes[in] == nil {
+				dex.Includes[in] = map[string]*Node{}
+			}
 			dex.Includes[in][n.ID] = n
 		}
 	}
 }
 
+// MapLinks updates the internal Links and Backlinks maps from the
+// Links of every node in the Nodes field. Links is keyed to the ID of
+// each node and contains every node it links to (keyed by ID and nil if
+// not in the index). Backlinks is the inverse providing a quick way to
+// lookup every node that links to a specific node ("what links here").
+// Since Links are only set by ReadNode these maps are only useful on an
+// Index from ScanIndex (or UpdateIndex for those nodes reread). Before
+// MapLinks is called both maps are nil.
+func (dex *Index) MapLinks() {
+	ids := dex.IDs
+	if ids == nil {
+		ids = make(map[string]*Node, len(dex.Nodes))
+		for _, n := range dex.Nodes {
+			ids[n.ID] = n
+		}
+	}
+	dex.Links = make(map[string]map[string]*Node, len(dex.Nodes))
+	dex.Backlinks = make(map[string]map[string]*Node, len(dex.Nodes))
+	for _, n := range dex.Nodes {
+		if dex.Links[n.ID] == nil {
+			dex.Links[n.ID] = map[string]*Node{}
+		}
+		if dex.Backlinks[n.ID] == nil {
+			dex.Backlinks[n.ID] = map[string]*Node{}
+		}
+		for _, to := range n.Links {
+			dex.Links[n.ID][to] = ids[to]
+			if dex.Backlinks[to] == nil {
+				dex.Backlinks[to] = map[string]*Node{}
+			}
+			dex.Backlinks[to][n.ID] = n
+		}
+	}
+}
+
 // MarshalText fulfills the encoding.TextMarshaler interface by
 // returning the same tab-delimited text expected in any index file. An
 // error is never returned and a byte slice, even if length of zero, is
@@ -110,22 +169,76 @@ func (dex Index) MarshalText() ([]byte, error) {
 	return []byte(str), nil
 }
 
-/*
 // UnmarshalText fulfills the encoding.TextUnmarshaler interface while
 // preserving references to existing values. UnmarshalText preserves
 // referential integrity. Existing Nodes will have their fields updated
 // if detected during unmarshaling. New Nodes will be added. Any non-nil
-// map will be updated as well (IDs, Titles, Includes).
+// map will be updated as well (IDs, Titles, Includes). Blank lines are
+// skipped. Nodes not found in the text are left as they are.
 func (dex *Index) UnmarshalText(buf []byte) error {
+	ids := dex.IDs
+	if ids == nil {
+		ids = make(map[string]*Node, len(dex.Nodes))
+		for _, n := range dex.Nodes {
+			ids[n.ID] = n
+		}
+	}
+
 	s := bufio.NewScanner(strings.NewReader(string(buf)))
-	dex.MapIDs()
 	for s.Scan() {
-		node := s.Text()
-		// TODO
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		node := NewNodeFromLine(s.Text())
+		if old, has := ids[node.ID]; has {
+			dex.unmapNode(old)
+			old.Title = node.Title
+			old.Changed = node.Changed
+			old.Includes = node.Includes
+			node = old
+		} else {
+			dex.Nodes = append(dex.Nodes, node)
+			ids[node.ID] = node
+		}
+		dex.mapNode(node)
+	}
+
+	return s.Err()
+}
+
+// mapNode adds the node to every non-nil map.
+func (dex *Index) mapNode(n *Node) {
+	if dex.IDs != nil {
+		dex.IDs[n.ID] = n
+	}
+	if dex.Titles != nil {
+		dex.Titles[n.Title] = n
+	}
+	if dex.Includes != nil {
+		if dex.Includes[n.ID] == nil {
+			dex.Includes[n.ID] = map[string]*Node{}
+		}
+		for _, in := range n.Includes {
+			if dex.Includes[in] == nil {
+				dex.Includes[in] = map[string]*Node{}
+			}
+			dex.Includes[in][n.ID] = n
+		}
+	}
+}
+
+// unmapNode removes the node's Title and Includes from any non-nil
+// map so that it can be changed and added again with mapNode.
+func (dex *Index) unmapNode(n *Node) {
+	if dex.Titles != nil && dex.Titles[n.Title] == n {
+		delete(dex.Titles, n.Title)
+	}
+	if dex.Includes != nil {
+		for _, in := range n.Includes {
+			delete(dex.Includes[in], n.ID)
+		}
 	}
-	return nil
 }
-*/
 
 // String fulfills the fmt.Stringer interface by returning the same
 // tab-delimited text expected in any index file. See MarshalText.
@@ -191,7 +304,8 @@ func ParseIndex(in any) (*Index, error) {
 
 // FetchIndex fetches the data from the target URL and passes it to
 // ParseIndex returning any error and always returning an index pointer.
-// If the URL does not end with IndexFileName then it is added.
+// If the URL does not end with IndexFileName then it is added. See
+// Remote for control over the client and cancellation.
 func FetchIndex(kegurl string) (*Index, error) {
 
 	url := kegurl + `/` + IndexFileName
@@ -217,15 +331,188 @@ func FetchIndex(kegurl string) (*Index, error) {
 	return dex, nil
 }
 
+// ScanWorkers is the maximum number of content node directories read
+// at the same time by ScanIndex.
+var ScanWorkers = runtime.NumCPU()
+
 // ScanIndex takes the path to a keg directory and scans all the
 // directories with node ids for names. Each content node directory is
-// passed to ReadNode and the new node is appended to the
-// Nodes slice of the Index. An Index is always returned even if empty.
+// passed to ReadNode (concurrently, see ScanWorkers) and the new node
+// (with its ID set to the directory name) is appended to the Nodes
+// slice of the Index in the same order as returned by NodeDirs. Nodes
+// that fail to be read are left out and reported together in a single
+// ErrScan. An Index is always returned even if empty (including when
+// kegpath cannot be read, which is returned as the error).
 func ScanIndex(kegpath string) (*Index, error) {
-	dex := new(Index)
-	var err error
-	// TODO
-	return dex, err
+	dex := NewIndex()
+	dirs, _, _, err := nodeDirs(kegpath)
+	if err != nil {
+		return dex, err
+	}
+	nodes, failed := readNodes(dirs)
+	dex.Nodes = append(dex.Nodes, nodes...)
+	if len(failed) > 0 {
+		return dex, failed
+	}
+	return dex, nil
+}
+
+// readNodes passes every dirpath to ReadNode (see scanDirs) and
+// returns the nodes successfully read (in the same order as dirs) along
+// with any that failed.
+func readNodes(dirs []string) ([]*Node, ErrScan) {
+	nodes := make([]*Node, len(dirs))
+	failed := scanDirs(dirs, func(i int) (err error) {
+		nodes[i], err = ReadNode(dirs[i])
+		nodes[i].ID = filepath.Base(dirs[i])
+		return
+	})
+	read := make([]*Node, 0, len(nodes))
+	for _, n := range nodes {
+		if _, has := failed[n.ID]; !has {
+			read = append(read, n)
+		}
+	}
+	return read, failed
+}
+
+// scanDirs calls read with the index of every one of the content node
+// directories using no more than ScanWorkers at a time and returns every
+// error keyed to the ID (directory name) of the node.
+func scanDirs(dirs []string, read func(i int) error) ErrScan {
+	errs := make([]error, len(dirs))
+
+	workers := ScanWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = read(i)
+			}
+		}()
+	}
+	for i := range dirs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := ErrScan{}
+	for i, err := range errs {
+		if err != nil {
+			failed[filepath.Base(dirs[i])] = err
+		}
+	}
+	return failed
+}
+
+// IndexUpdate reports the node IDs affected by UpdateIndex.
+type IndexUpdate struct {
+	Added   []string // new node directories
+	Updated []string // README.md modified since Changed
+	Removed []string // node directory no longer exists
+}
+
+// Changed returns true if anything was added, updated, or removed.
+func (u IndexUpdate) Changed() bool {
+	return len(u.Added)+len(u.Updated)+len(u.Removed) > 0
+}
+
+// UpdateIndex reads the existing index (see ReadIndex) from kegpath and
+// only rereads (see ReadNode) those content nodes that are new or
+// whose README.md file has been modified since the Changed time
+// recorded in the index. Nodes whose directories no longer exist are
+// removed. Existing Node references in the returned Index are updated
+// in place. If there is no index file at all every node is considered
+// added. The index file is only written (see WriteIndex) if something
+// changed. Nodes that could not be read are reported in an ErrScan and
+// left unchanged.
+func UpdateIndex(kegpath string) (*Index, *IndexUpdate, error) {
+	up := new(IndexUpdate)
+
+	dex, err := ReadIndex(kegpath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, up, err
+		}
+		dex = NewIndex()
+	}
+	dex.MapIDs()
+
+	dirs, _, _, err := nodeDirs(kegpath)
+	if err != nil {
+		return nil, up, err
+	}
+	exist := make(map[string]bool, len(dirs))
+	stale := []string{}
+	for _, dir := range dirs {
+		id := filepath.Base(dir)
+		exist[id] = true
+		n, has := dex.IDs[id]
+		if !has {
+			stale = append(stale, dir)
+			continue
+		}
+		// any that cannot be stat'ed are reread to report why
+		info, err := os.Stat(filepath.Join(dir, `README.md`))
+		if err != nil || info.ModTime().Truncate(time.Second).After(n.Changed) {
+			stale = append(stale, dir)
+		}
+	}
+
+	nodes, failed := readNodes(stale)
+	for _, n := range nodes {
+		if old, has := dex.IDs[n.ID]; has {
+			*old = *n
+			up.Updated = append(up.Updated, n.ID)
+			continue
+		}
+		dex.Nodes = append(dex.Nodes, n)
+		dex.IDs[n.ID] = n
+		up.Added = append(up.Added, n.ID)
+	}
+
+	kept := make([]*Node, 0, len(dex.Nodes))
+	for _, n := range dex.Nodes {
+		if !exist[n.ID] {
+			delete(dex.IDs, n.ID)
+			up.Removed = append(up.Removed, n.ID)
+			continue
+		}
+		kept = append(kept, n)
+	}
+	dex.Nodes = kept
+
+	if up.Changed() {
+		if err := WriteIndex(kegpath, dex); err != nil {
+			return dex, up, err
+		}
+	}
+
+	if len(failed) > 0 {
+		return dex, up, failed
+	}
+	return dex, up, nil
+}
+
+// WriteIndex writes the MarshalText form of the Index to the file
+// IndexFileName within kegpath replacing it atomically and setting
+// dex.File.
+func WriteIndex(kegpath string, dex *Index) error {
+	buf, _ := dex.MarshalText()
+	file := filepath.Join(kegpath, IndexFileName)
+	if err := writeFile(file, buf); err != nil {
+		return err
+	}
+	dex.File = file
+	return nil
 }
 
 // Validate iterates over every node calling Validate on it and adding
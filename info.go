@@ -1,3 +1,244 @@
 package keg
 
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
 const InfoFileName = `keg`
+
+// KegInfo contains the information from the keg info file
+// (InfoFileName) at the root of every keg directory. The file uses
+// a simplified subset of YAML consisting of only the following:
+//
+//     key:     value
+//
+//     summary:
+//       Any number of lines indented by two spaces (blank lines
+//       included) that end with the first line not indented.
+//
+//     indexes:
+//       - file: dex/changes.md
+//         summary: latest changes
+//
+// The updated line must always be the first line of the file. Every
+// other field is optional. Any unknown single line keys are preserved
+// in Other so that reading and then writing the file never loses
+// information.
+type KegInfo struct {
+	File    string            // if from file system
+	Updated time.Time         // updated (first line, IsoTimeLayout)
+	KegV    string            // kegv
+	Title   string            // title
+	URL     string            // url
+	Creator string            // creator
+	State   string            // state
+	Summary string            // summary (without indentation)
+	Indexes []IndexInfo       // indexes
+	Other   map[string]string // any other single line keys
+}
+
+// IndexInfo is a single entry in the indexes list of a KegInfo.
+type IndexInfo struct {
+	File    string
+	Summary string
+}
+
+// ReadKegInfo reads the InfoFileName file within kegpath and returns
+// ParseKegInfo with File set.
+func ReadKegInfo(kegpath string) (*KegInfo, error) {
+	file := filepath.Join(kegpath, InfoFileName)
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := ParseKegInfo(buf)
+	if info != nil {
+		info.File = file
+	}
+	return info, err
+}
+
+// ParseKegInfo parses any of the following into a new KegInfo:
+//
+// * string
+// * []byte
+// * []rune
+// * io.Reader
+//
+// An error is returned if the first line is not a valid updated line
+// (see IsoTimeLayout) or if any other line cannot be parsed. A KegInfo
+// containing everything parsed before the error is always returned.
+func ParseKegInfo(in any) (*KegInfo, error) {
+	info := new(KegInfo)
+
+	s := bufio.NewScanner(strings.NewReader(stringify(in)))
+
+	if !s.Scan() || !strings.HasPrefix(s.Text(), `updated:`) {
+		return info, fmt.Errorf(_InfoUpdatedFirst)
+	}
+	val := strings.TrimSpace(strings.TrimPrefix(s.Text(), `updated:`))
+	updated, err := time.Parse(IsoTimeLayout, val)
+	if err != nil {
+		return info, fmt.Errorf(_InfoLine, 1, s.Text())
+	}
+	info.Updated = updated
+
+	// block is the key of the indented block currently being parsed
+	var block string
+	var summary []string
+
+	for line := 2; s.Scan(); line++ {
+		text := s.Text()
+
+		if block != "" && (text == "" || text[0] == ' ') {
+			switch block {
+
+			case `summary`:
+				summary = append(summary, strings.TrimPrefix(text, `  `))
+
+			case `indexes`:
+				item := strings.TrimSpace(text)
+				switch {
+				case item == "":
+				case strings.HasPrefix(item, `- file:`):
+					info.Indexes = append(info.Indexes, IndexInfo{
+						File: strings.TrimSpace(strings.TrimPrefix(item, `- file:`)),
+					})
+				case strings.HasPrefix(item, `summary:`) && len(info.Indexes) > 0:
+					info.Indexes[len(info.Indexes)-1].Summary =
+						strings.TrimSpace(strings.TrimPrefix(item, `summary:`))
+				default:
+					return info, fmt.Errorf(_InfoLine, line, text)
+				}
+
+			}
+			continue
+		}
+		block = ""
+
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		key, val, found := strings.Cut(text, `:`)
+		if !found || strings.ContainsAny(key, " \t") {
+			return info, fmt.Errorf(_InfoLine, line, text)
+		}
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case `updated`:
+			return info, fmt.Errorf(_InfoUpdatedFirst)
+		case `kegv`:
+			info.KegV = val
+		case `title`:
+			info.Title = val
+		case `url`:
+			info.URL = val
+		case `creator`:
+			info.Creator = val
+		case `state`:
+			info.State = val
+		case `summary`:
+			if val != "" {
+				info.Summary = val
+				continue
+			}
+			block = key
+		case `indexes`:
+			block = key
+		default:
+			if info.Other == nil {
+				info.Other = map[string]string{}
+			}
+			info.Other[key] = val
+		}
+	}
+
+	if summary != nil {
+		info.Summary = strings.TrimRight(strings.Join(summary, "\n"), "\n ")
+	}
+
+	return info, nil
+}
+
+// Touch sets Updated to the current UTC time (to the second).
+func (info *KegInfo) Touch() {
+	info.Updated = time.Now().UTC().Truncate(time.Second)
+}
+
+// MarshalText fulfills the encoding.TextMarshaler interface by
+// returning the same simplified YAML expected in any keg info file
+// (see KegInfo) beginning with the updated line. Empty fields are
+// omitted. An error is never returned.
+func (info KegInfo) MarshalText() ([]byte, error) {
+	var b strings.Builder
+
+	field := func(key, val string) {
+		if val != "" {
+			fmt.Fprintf(&b, "%-9v%v\n", key+`:`, val)
+		}
+	}
+
+	field(`updated`, info.Updated.Format(IsoTimeLayout))
+	field(`kegv`, info.KegV)
+
+	group := []string{info.Title, info.URL, info.Creator, info.State}
+	if strings.Join(group, "") != "" {
+		b.WriteString("\n")
+		field(`title`, info.Title)
+		field(`url`, info.URL)
+		field(`creator`, info.Creator)
+		field(`state`, info.State)
+	}
+
+	if len(info.Other) > 0 {
+		b.WriteString("\n")
+		other := keys(info.Other)
+		sort.Strings(other)
+		for _, k := range other {
+			field(k, info.Other[k])
+		}
+	}
+
+	if info.Summary != "" {
+		b.WriteString("\nsummary:\n")
+		for _, line := range strings.Split(info.Summary, "\n") {
+			b.WriteString(`  ` + line + "\n")
+		}
+	}
+
+	if len(info.Indexes) > 0 {
+		b.WriteString("\nindexes:\n")
+		for _, i := range info.Indexes {
+			b.WriteString(`  - file: ` + i.File + "\n")
+			if i.Summary != "" {
+				b.WriteString(`    summary: ` + i.Summary + "\n")
+			}
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// String fulfills the fmt.Stringer interface. See MarshalText.
+func (info KegInfo) String() string { b, _ := info.MarshalText(); return string(b) }
+
+// WriteKegInfo writes the MarshalText form of the KegInfo to the
+// InfoFileName file within kegpath replacing it atomically and setting
+// info.File.
+func WriteKegInfo(kegpath string, info *KegInfo) error {
+	buf, _ := info.MarshalText()
+	file := filepath.Join(kegpath, InfoFileName)
+	if err := writeFile(file, buf); err != nil {
+		return err
+	}
+	info.File = file
+	return nil
+}
@@ -0,0 +1,22 @@
+package keg
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKegInfo_MarshalText(t *testing.T) {
+	buf, err := os.ReadFile(`testdata/samplekeg/keg`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseKegInfo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := info.MarshalText()
+	if strings.TrimSpace(string(out)) != strings.TrimSpace(string(buf)) {
+		t.Errorf("failed to round trip keg info:\n%v", string(out))
+	}
+}
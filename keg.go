@@ -1,16 +1,324 @@
 package keg
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"time"
+
+	"github.com/rwxrob/keg/kegml"
 )
 
+const DexDirName = `dex`
+
+// Keg bundles everything about a knowledge exchange graph directory
+// that is commonly needed together: its info file, its Index, the
+// content node directories, and the dex directory. Use OpenKeg to
+// create one from a keg directory.
+type Keg struct {
+	Path  string   // absolute path to keg directory
+	Info  *KegInfo // from InfoFileName
+	Index *Index   // from IndexFileName (or ScanIndex if none)
+	Dirs  []string // content node directories (see NodeDirs)
+	Dex   string   // path to DexDirName directory
+	Low   int      // lowest node ID (see NodeDirs)
+	High  int      // highest node ID (see NodeDirs)
+}
+
+// OpenKeg reads the keg info file (see ReadKegInfo) and index (see
+// ReadIndex) from the keg directory at path and notes all of its node
+// directories (see NodeDirs). If there is no index file one is created
+// in memory with ScanIndex (but not written, see Reindex). The IDs map
+// of the Index is always updated (see MapIDs). Returns an error if
+// path is not a keg directory (no InfoFileName). Nodes that fail to be
+// scanned are left out and reported in an ErrScan returned along with
+// the usable Keg (as with Reindex).
+func OpenKeg(path string) (*Keg, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k := new(Keg)
+	k.Path = abs
+	k.Dex = filepath.Join(abs, DexDirName)
+
+	k.Info, err = ReadKegInfo(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	k.Index, err = ReadIndex(abs)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		k.Index, err = ScanIndex(abs)
+		if _, ok := err.(ErrScan); err != nil && !ok {
+			return nil, err
+		}
+	}
+	k.Index.MapIDs()
+
+	k.Dirs, k.Low, k.High = NodeDirs(abs)
+
+	return k, err
+}
+
+// NodePath returns the path to the content node directory for id
+// whether or not it exists.
+func (k *Keg) NodePath(id string) string { return filepath.Join(k.Path, id) }
+
+// Node returns the Node from the Index with the given id or nil if
+// none.
+func (k *Keg) Node(id string) *Node {
+	if k.Index.IDs == nil {
+		k.Index.MapIDs()
+	}
+	return k.Index.IDs[id]
+}
+
+// Nodes returns the Nodes of the Index in their current order (see
+// SortByID and SortByChanges).
+func (k *Keg) Nodes() []*Node { return k.Index.Nodes }
+
+// Body returns the content of the README.md file of the content node
+// with the given id.
+func (k *Keg) Body(id string) ([]byte, error) {
+	if err := assertID(id); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(k.NodePath(id), `README.md`))
+}
+
+// Reindex scans every content node directory (see ScanIndex), writes
+// the new index file (see WriteIndex), and updates Index, Dirs, Low,
+// and High. Nodes that fail to be read are reported in an ErrScan
+// but the index is still written without them.
+func (k *Keg) Reindex() error {
+	dex, err := ScanIndex(k.Path)
+	if _, ok := err.(ErrScan); err != nil && !ok {
+		return err
+	}
+	if werr := WriteIndex(k.Path, dex); werr != nil {
+		return werr
+	}
+	dex.MapIDs()
+	k.Index = dex
+	k.Dirs, k.Low, k.High = NodeDirs(k.Path)
+	return err
+}
+
+// Lint passes the README.md file of every content node directory (see
+// NodeDirs) to kegml.Lint and returns all the diagnostics found with
+// their File set to the path of the README.md relative to the keg
+// directory (ex: 1/README.md) in increasing order of node ID. Files
+// that cannot be read are reported together in a single ErrScan.
+func (k *Keg) Lint() ([]kegml.Diagnostic, error) {
+	diags := []kegml.Diagnostic{}
+	failed := ErrScan{}
+
+	for _, dir := range k.sortedDirs() {
+		id := filepath.Base(dir)
+		buf, err := os.ReadFile(filepath.Join(dir, `README.md`))
+		if err != nil {
+			failed[id] = err
+			continue
+		}
+		for _, d := range kegml.Lint(buf) {
+			d.File = filepath.Join(id, `README.md`)
+			diags = append(diags, d)
+		}
+	}
+
+	if len(failed) > 0 {
+		return diags, failed
+	}
+	return diags, nil
+}
+
+// Fix passes the README.md file of every content node directory (see
+// NodeDirs) to kegml.Fix in increasing order of node ID and returns the
+// diagnostics describing every change with their File set as with Lint.
+// If diff is not nil the changes to every file are written to it as
+// a unified diff (see WriteDiff). If dryrun is true nothing is changed.
+// Otherwise, every fixed file is written (see writeFile) and the Index
+// is updated (see UpdateIndex). Files that cannot be read or written (or
+// reread by UpdateIndex) are reported together in a single ErrScan.
+func (k *Keg) Fix(dryrun bool, diff io.Writer) ([]kegml.Diagnostic, error) {
+	diags := []kegml.Diagnostic{}
+	failed := ErrScan{}
+	var changed bool
+
+	for _, dir := range k.sortedDirs() {
+		id := filepath.Base(dir)
+		name := filepath.Join(id, `README.md`)
+		file := filepath.Join(dir, `README.md`)
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			failed[id] = err
+			continue
+		}
+		fixed, fixes := kegml.Fix(buf)
+		if len(fixes) == 0 {
+			continue
+		}
+		for _, d := range fixes {
+			d.File = name
+			diags = append(diags, d)
+		}
+		if diff != nil {
+			if err := WriteDiff(diff, name, buf, fixed); err != nil {
+				return diags, err
+			}
+		}
+		if dryrun {
+			continue
+		}
+		if err := writeFile(file, fixed); err != nil {
+			failed[id] = err
+			continue
+		}
+		changed = true
+	}
+
+	if changed {
+		dex, _, err := UpdateIndex(k.Path)
+		if dex != nil {
+			k.Index = dex
+		}
+		scan, ok := err.(ErrScan)
+		if err != nil && !ok {
+			return diags, err
+		}
+		for id, err := range scan {
+			failed[id] = err
+		}
+	}
+
+	if len(failed) > 0 {
+		return diags, failed
+	}
+	return diags, nil
+}
+
+// sortedDirs returns the content node directories (see NodeDirs) in
+// increasing order of node ID.
+func (k *Keg) sortedDirs() []string {
+	dirs, _, _ := NodeDirs(k.Path)
+	sort.Slice(dirs, func(i, j int) bool {
+		a, _ := strconv.Atoi(filepath.Base(dirs[i]))
+		b, _ := strconv.Atoi(filepath.Base(dirs[j]))
+		return a < b
+	})
+	return dirs
+}
+
+// Create creates a new content node with the given title and body
+// (which may be nil) and returns it. The ID is the one after the
+// highest existing node directory (see NodeDirs) but never the zero
+// node (0) which is reserved. The node directory is created exclusively
+// so that if another process creates a node with the same ID at the
+// same time the next ID is tried instead. The README.md file begins
+// with the title line followed by a blank line and the body and is
+// written to a temporary file first (see writeFile) so that it is never
+// seen partially written. The new node is appended to the index file
+// with a single write (see appendIndex) and added to the Index. If
+// anything fails the new node directory is removed.
+func (k *Keg) Create(title string, body io.Reader) (*Node, error) {
+	if err := assertTitle(title); err != nil {
+		return nil, err
+	}
+
+	_, _, high := NodeDirs(k.Path)
+	id := high + 1
+	if id < 1 {
+		id = 1
+	}
+	for {
+		err := os.Mkdir(k.NodePath(strconv.Itoa(id)), 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		id++
+	}
+
+	node := new(Node)
+	node.ID = strconv.Itoa(id)
+	node.Title = title
+	node.Includes = []string{}
+	dir := k.NodePath(node.ID)
+
+	buf := []byte("# " + title + "\n")
+	if body != nil {
+		more, err := io.ReadAll(body)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+		if len(more) > 0 {
+			buf = append(buf, '\n')
+			buf = append(buf, more...)
+		}
+	}
+
+	file := filepath.Join(dir, `README.md`)
+	if err := writeFile(file, buf); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	node.Changed = lastMod(file).UTC()
+
+	if err := appendIndex(k.Path, node); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	k.Index.Add(node)
+	if k.Index.IDs != nil {
+		k.Index.IDs[node.ID] = node
+	}
+	k.Dirs, k.Low, k.High = NodeDirs(k.Path)
+
+	return node, nil
+}
+
+// appendIndex appends the node as a single line to the index file
+// within kegpath (creating it if needed). The line is written with
+// a single O_APPEND write so that concurrent appends from different
+// processes do not interleave.
+func appendIndex(kegpath string, node *Node) error {
+	file := filepath.Join(kegpath, IndexFileName)
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line, _ := node.MarshalText()
+	line = append(line, '\n')
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if r, err := os.Open(file); err == nil {
+			r.ReadAt(last, info.Size()-1)
+			r.Close()
+		}
+		if last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	_, err = f.Write(line)
+	return err
+}
+
 func exists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
@@ -48,13 +356,52 @@ func stringify(in any) string {
 	}
 }
 
+// writeFile writes buf to a temporary file in the same directory as
+// file and then renames it so that readers never see a partial file.
+func writeFile(file string, buf []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(file), `.`+filepath.Base(file)+`-*`)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
 func fetch(url string) ([]byte, error) {
+	return fetchContext(context.Background(), nil, url)
+}
+
+// fetchContext gets the body of the url with the client (RemoteClient
+// if nil) returning an ErrFetch (with the body) if the response status
+// is not 2xx.
+func fetchContext(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = RemoteClient
+	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return body, err
@@ -82,8 +429,15 @@ func DirIsNode(path string) bool {
 // values are also returned. Only positive integers are checked. This is
 // useful when using directory names as database-friendly unique primary
 // keys for other file system content. An empty slice with -1 low and
-// high is returned if there are no results found.
+// high is returned if there are no results found (or kegpath cannot be
+// read, see nodeDirs).
 func NodeDirs(kegpath string) (paths []string, low, high int) {
+	paths, low, high, _ = nodeDirs(kegpath)
+	return
+}
+
+// nodeDirs is NodeDirs but also returns the error reading kegpath.
+func nodeDirs(kegpath string) (paths []string, low, high int, err error) {
 	low, high = -1, -1
 	entries, err := os.ReadDir(kegpath)
 	if err != nil {
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -79,3 +82,51 @@ func ExampleAssertID() {
 	// Output:
 	// Node identifier must be positive integer
 }
+
+func TestKeg_Create_concurrent(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, InfoFileName), []byte("updated: 2022-11-26 19:33:24Z\n"), 0600)
+
+	const count = 20
+	ids := make(chan string, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// other nodes may be mid-creation (ErrScan)
+			k, err := OpenKeg(dir)
+			if k == nil {
+				t.Error(err)
+				return
+			}
+			n, err := k.Create(`Concurrent`, strings.NewReader("Body\n"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- n.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[string]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf(`duplicate id: %v`, id)
+		}
+		if id == `0` {
+			t.Error(`allocated reserved zero node`)
+		}
+		seen[id] = true
+	}
+
+	dex, err := ReadIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dex.Nodes) != count {
+		t.Errorf(`expected %v nodes in index, got %v`, count, len(dex.Nodes))
+	}
+}
@@ -0,0 +1,277 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Node types. Block types (ending in B, plus Separator) are produced by
+// the first pass (see kegml.ParseBlocks). Semantic block types and span
+// types are produced by the second pass (see kegml.Parse).
+const (
+	Unknown = iota
+	Blocks
+	TitleB
+	IncListB
+	Separator
+	BulListB
+	NumListB
+	FigureB
+	QuoteB
+	LatexB
+	FencedB
+	DivB
+	NoteB
+	TableB
+	ParaB
+
+	// semantic blocks
+	Document
+	Title
+	Includes
+	Include
+	Bulleted
+	Numbered
+	Item
+	Figure
+	Quote
+	Latex
+	Fenced
+	Division
+	Table
+	Row
+	Delim
+	Cell
+	Paragraph
+	Footnotes
+	Footnote
+
+	// spans
+	Plain
+	Inflect
+	Beacon
+	Lede
+	Math
+	Code
+	URL
+	Deleted
+	Link
+	FootRef
+)
+
+// Rules contains the names of every node type (T) from the PEGN
+// specification (kegml.pegn) in the same order as the constants.
+var Rules = []string{
+	`Unknown`,
+	`Blocks`,
+	`TitleB`,
+	`IncListB`,
+	`Separator`,
+	`BulListB`,
+	`NumListB`,
+	`FigureB`,
+	`QuoteB`,
+	`LatexB`,
+	`FencedB`,
+	`DivB`,
+	`NoteB`,
+	`TableB`,
+	`ParaB`,
+	`Document`,
+	`Title`,
+	`Includes`,
+	`Include`,
+	`Bulleted`,
+	`Numbered`,
+	`Item`,
+	`Figure`,
+	`Quote`,
+	`Latex`,
+	`Fenced`,
+	`Division`,
+	`Table`,
+	`Row`,
+	`Delim`,
+	`Cell`,
+	`Paragraph`,
+	`Footnotes`,
+	`Footnote`,
+	`Plain`,
+	`Inflect`,
+	`Beacon`,
+	`Lede`,
+	`Math`,
+	`Code`,
+	`URL`,
+	`Deleted`,
+	`Link`,
+	`FootRef`,
+}
+
+// IsSpan returns true if the type (T) is one of the span types.
+func IsSpan(t int) bool { return t >= Plain && t <= FootRef }
+
+// Node represents the output of a KEGML parser. Each comes with
+// its own copy of the []rune slice (R) originally submitted to the
+// parser. Even though all nodes have their own copies of the []rune
+// slice the underlying array is identical. This is because slices are
+// an abstraction in Go and all slices created from the same original
+// data always share the same memory.
+//
+// The inclusive beginning of the matching data is preserved (B) as is
+// the non-inclusive end (E). And for rules that have extraneous runes
+// that should not be captured (such as the hashtag and space of
+// a title or the fence lines of a fenced block) the beginning (XB) and
+// end (XE) of the capture runes is also preserved. For any given rule
+// there can only be one capture range. When there are no extraneous
+// runes the capture range is the same as the match.
+//
+// The value (V) is set for rules that have a single meaningful string
+// value beyond their runes (the title text, the language of a fenced
+// block, and such).
+//
+// The line and column of the beginning (Pos) and ending (End) of the
+// match are also preserved for human consumption (see SetPositions).
+//
+// For non-leaf nodes child nodes under this node are assigned to Under.
+type Node struct {
+	T     int      `json:"T"`          // type
+	V     string   `json:",omitempty"` // value
+	R     []rune   `json:"-"`          // copy of slice abstraction only (not underlying array)
+	B     int      `json:"-"`          // beginning of match (inclusive)
+	E     int      `json:"-"`          // ending of match (non-inclusive)
+	XB    int      `json:"-"`          // beginning of capture (inclusive)
+	XE    int      `json:"-"`          // ending of capture (non-inclusive)
+	Pos   Position `json:"-"`          // line and column of B
+	End   Position `json:"-"`          // line and column of E
+	Under []*Node  `json:"-"`          // child nodes
+}
+
+// Position is the line and column (both beginning with 1) of a rune
+// offset. Columns count runes, not bytes.
+type Position struct {
+	Line int
+	Col  int
+}
+
+// String fulfills the fmt.Stringer interface with line:col.
+func (p Position) String() string { return fmt.Sprintf("%v:%v", p.Line, p.Col) }
+
+// Lines contains the rune offset of the beginning of every line of
+// some runes and is used to quickly convert offsets into Positions.
+type Lines []int
+
+// NewLines returns the Lines of the runes passed.
+func NewLines(r []rune) Lines {
+	lines := Lines{0}
+	for i, c := range r {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// Position returns the Position of the rune offset.
+func (l Lines) Position(offset int) Position {
+	i := sort.Search(len(l), func(i int) bool { return l[i] > offset }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return Position{i + 1, offset - l[i] + 1}
+}
+
+// SetPositions sets the Pos and End of this Node and every Node under
+// it from their offsets (B and E) within R. Parsers call this once
+// after parsing.
+func (n *Node) SetPositions() {
+	lines := NewLines(n.R)
+	n.Walk(func(u *Node) bool {
+		u.Pos = lines.Position(u.B)
+		u.End = lines.Position(u.E)
+		return true
+	})
+}
+
+// Name returns the name of the type (T) from Rules.
+func (n Node) Name() string {
+	if n.T < 0 || n.T >= len(Rules) {
+		return Rules[Unknown]
+	}
+	return Rules[n.T]
+}
+
+// Text returns the runes matched (B to E) as a string.
+func (n Node) Text() string { return string(n.R[n.B:n.E]) }
+
+// Capture returns the runes captured (XB to XE) as a string.
+func (n Node) Capture() string { return string(n.R[n.XB:n.XE]) }
+
+// Add appends the nodes passed to Under.
+func (n *Node) Add(u ...*Node) { n.Under = append(n.Under, u...) }
+
+// Walk passes this Node and every Node under it to the given function
+// in a synchronous, depth-first, preorder way. If the function returns
+// false the nodes under the one passed are skipped.
+func (n *Node) Walk(do func(n *Node) bool) {
+	if !do(n) {
+		return
+	}
+	for _, u := range n.Under {
+		u.Walk(do)
+	}
+}
+
+// ------------------------------ Printer -----------------------------
+
+// just for marshaling
+type jsnode struct {
+	T string  `json:"T"`
+	V string  `json:"V,omitempty"`
+	N []*Node `json:"N,omitempty"`
+}
+
+// MarshalJSON fulfills the json.Marshaler interface by first creating
+// a copy of itself with the type name as T and Under as N and then
+// marshaling with an encoder that has had SetEscapeHTML set to false
+// and trims the extraneous newline added by json.Encoder.Encode. See
+// String, Log, and Print as well.
+func (s Node) MarshalJSON() ([]byte, error) {
+	n := new(jsnode)
+	n.T = s.Name()
+	n.V = s.V
+	n.N = s.Under
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(n)
+	if err != nil {
+		return nil, err
+	}
+	byt := buf.Bytes()
+	return byt[:len(byt)-1], err
+}
+
+// String returns the MarshalJSON version or the string "null" if an
+// error occurred. An error is also logged with log.Print. No additional
+// line return is added.
+func (s Node) String() string {
+	byt, err := s.MarshalJSON()
+	if err != nil {
+		log.Println(err)
+		return `null`
+	}
+	return string(byt)
+}
+
+// Print uses fmt.Print to print.
+func (s Node) Print() { fmt.Print(s.String()) }
+
+// Println uses fmt.Println to print.
+func (s Node) Println() { fmt.Println(s.String()) }
+
+// Log uses log.Print to print.
+func (s Node) Log() { log.Print(s.String()) }
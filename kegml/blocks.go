@@ -0,0 +1,220 @@
+package kegml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+var (
+	fencedOpen = regexp.MustCompile("^(`{3,8}|~{3,8})(.*)$")
+	divOpen    = regexp.MustCompile(`^(:{3,8})(.*)$`)
+	noteOpen   = regexp.MustCompile(`^\[\^[^\]\s]+\]:`)
+	numOpen    = regexp.MustCompile(`^\d{1,8}\. `)
+)
+
+// span is the beginning (inclusive) and ending (non-inclusive) rune
+// offset of a single line not including the line ending (LF or CRLF).
+type span struct{ b, e int }
+
+// splitLines returns the spans of every line in r.
+func splitLines(r []rune) []span {
+	var lines []span
+	b := 0
+	for i, c := range r {
+		if c != '\n' {
+			continue
+		}
+		e := i
+		if e > b && r[e-1] == '\r' {
+			e--
+		}
+		lines = append(lines, span{b, e})
+		b = i + 1
+	}
+	if b < len(r) {
+		lines = append(lines, span{b, len(r)})
+	}
+	return lines
+}
+
+func isBlank(r []rune, l span) bool {
+	return strings.TrimSpace(string(r[l.b:l.e])) == ""
+}
+
+// ParseBlocks parses a KEGML README.md document into its major blocks
+// of runes (the Blocks rule of kegml.pegn) without any semantic
+// consideration for how those blocks are further parsed. Input may be
+// any of the following:
+//
+// * string
+// * []byte
+// * []rune
+// * io.Reader
+//
+//            BLOCK     │  TOKEN   │        CONTAINS
+//      ────────────────┼──────────┼──────────────────────────
+//        Title         │ #        │ Inflect, Math, Code
+//        Bulleted List │ *  -  +  │ All but Lede
+//        Numbered List │ 1.       │ All but Lede
+//        Include List  │ * [      │ Inflect, Math, Code
+//        Footnotes     │ [^       │ All buf Lede
+//        Fenced        │ ``` ~~~  │ Runes
+//        Division      │ :::      │ Runes
+//        Quote         │ >        │ All but URL, Link, Lede
+//        Math          │ $$       │ Runes
+//        Figure        │ ![       │ Inflect, Math, Code
+//        Separator     │ ----     │ None
+//        Table         │ |        │ All but Lede
+//        Paragraph     │ None     │ All but URL
+//
+// Every block is returned as an ast.Node (under a single ast.Blocks
+// node) with its type (T), the original runes (R), and the offsets of
+// the block within them (B, E) along with their line and column (Pos,
+// End). Title, fenced, division, and math blocks also set the capture
+// offsets (XB, XE) to exclude their tokens and fence lines and set V to
+// the title text or the text following the opening fence token
+// (language, attributes).
+//
+// Blocks end with a blank line or the end of the data except for the
+// following: Title and Separator blocks are always a single line and
+// fenced, division, and math blocks end with their closing token line
+// (which may be followed directly by another block).
+//
+// Parsing never stops early. The first error encountered (a missing
+// title or an unclosed fenced, division, or math block) is returned
+// along with every block. Unclosed blocks continue to the end of the
+// data. Structural rules (only one title, footnotes last, and such) are
+// not enforced here (see Lint).
+func ParseBlocks(in any) (*ast.Node, error) {
+	r := []rune(stringify(in))
+	root := &ast.Node{T: ast.Blocks, R: r, E: len(r), XE: len(r)}
+	lines := splitLines(r)
+
+	var err error
+	for i := 0; i < len(lines); {
+		if isBlank(r, lines[i]) {
+			i++
+			continue
+		}
+		n, next, e := parseBlock(r, lines, i)
+		if e != nil && err == nil {
+			err = e
+		}
+		root.Add(n)
+		i = next
+	}
+
+	if err == nil && (len(root.Under) == 0 || root.Under[0].T != ast.TitleB) {
+		err = newError(r, 0, _MissingTitle)
+	}
+
+	root.SetPositions()
+	return root, err
+}
+
+// parseBlock parses the block beginning with line i and returns it
+// along with the index of the next line after it.
+func parseBlock(r []rune, lines []span, i int) (*ast.Node, int, error) {
+	first := string(r[lines[i].b:lines[i].e])
+	trim := strings.TrimRight(first, " \t\r")
+	n := &ast.Node{R: r, B: lines[i].b, E: lines[i].e}
+
+	switch {
+
+	case strings.HasPrefix(first, `# `):
+		n.T = ast.TitleB
+		n.XB, n.XE = n.B+2, n.E
+		n.V = n.Capture()
+		return n, i + 1, nil
+
+	case trim == `----`:
+		n.T = ast.Separator
+		n.XB, n.XE = n.B, n.E
+		return n, i + 1, nil
+
+	case fencedOpen.MatchString(first):
+		f := fencedOpen.FindStringSubmatch(first)
+		n.T = ast.FencedB
+		n.V = strings.TrimSpace(f[2])
+		return closeBlock(n, r, lines, i, f[1])
+
+	case divOpen.MatchString(first):
+		f := divOpen.FindStringSubmatch(first)
+		n.T = ast.DivB
+		n.V = strings.TrimSpace(f[2])
+		return closeBlock(n, r, lines, i, f[1])
+
+	case trim == `$$`:
+		n.T = ast.LatexB
+		return closeBlock(n, r, lines, i, `$$`)
+
+	}
+
+	// everything else ends with a blank line (or end of data)
+	last := i
+	for last+1 < len(lines) && !isBlank(r, lines[last+1]) {
+		last++
+	}
+	n.E = lines[last].e
+	n.XB, n.XE = n.B, n.E
+
+	switch {
+	case noteOpen.MatchString(first):
+		n.T = ast.NoteB
+	case strings.HasPrefix(first, `![`):
+		n.T = ast.FigureB
+	case trim == `>` || strings.HasPrefix(first, `> `):
+		n.T = ast.QuoteB
+	case strings.HasPrefix(first, `|`):
+		n.T = ast.TableB
+	case isIncludeList(textLines(r, lines[i:last+1])):
+		n.T = ast.IncListB
+	case len(first) > 1 && strings.ContainsRune(`*+-`, rune(first[0])) && first[1] == ' ':
+		n.T = ast.BulListB
+	case numOpen.MatchString(first):
+		n.T = ast.NumListB
+	default:
+		n.T = ast.ParaB
+	}
+
+	return n, last + 1, nil
+}
+
+// closeBlock finds the line closing the fenced, division, or math block
+// n opened at line i with tok and sets the match and capture offsets
+// returning the index of the line after the closing one. If never
+// closed the block (and capture) continues to the end of the data.
+func closeBlock(n *ast.Node, r []rune, lines []span, i int, tok string) (*ast.Node, int, error) {
+	n.XB = lines[i].e
+	if i+1 < len(lines) {
+		n.XB = lines[i+1].b
+	}
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimRight(string(r[lines[j].b:lines[j].e]), " \t\r") != tok {
+			continue
+		}
+		n.E = lines[j].e
+		n.XE = lines[j].b
+		if j > i+1 {
+			n.XE = lines[j-1].e
+		}
+		return n, j + 1, nil
+	}
+	n.E = len(r)
+	n.XE = n.E
+	if n.XB > n.XE {
+		n.XB = n.XE
+	}
+	return n, len(lines), newError(r, n.B, fmt.Sprintf(_Unclosed, ast.Rules[n.T]))
+}
+
+func textLines(r []rune, lines []span) []string {
+	text := make([]string, len(lines))
+	for i, l := range lines {
+		text[i] = string(r[l.b:l.e])
+	}
+	return text
+}
@@ -0,0 +1,22 @@
+package kegml
+
+import (
+	"fmt"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+// Error is a parse error at a specific rune offset (B) of the source
+// which is also available as a line and column (Pos).
+type Error struct {
+	B   int          // rune offset (inclusive)
+	Pos ast.Position // line and column of B
+	Msg string       // message
+}
+
+// newError returns an Error at offset b of r.
+func newError(r []rune, b int, msg string) Error {
+	return Error{B: b, Pos: ast.NewLines(r).Position(b), Msg: msg}
+}
+
+func (e Error) Error() string { return fmt.Sprintf("%v: %v", e.Pos, e.Msg) }
@@ -2,10 +2,19 @@ package kegml_test
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/rwxrob/keg/kegml"
+	"github.com/rwxrob/keg/kegml/ast"
 )
 
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
 func ExampleParseTitle() {
 
 	//rat.Trace++
@@ -14,3 +23,450 @@ func ExampleParseTitle() {
 	// Output:
 	// Some title
 }
+
+func ExampleParseLinks() {
+
+	doc := "# Title\n\nSee [the zero node](../0) and [a\nfile](data.csv?T) or `[not](../9)`.\n" +
+		"Also ![figure](img.png), a footnote[^1], and [a site](https://example.com).\n\n" +
+		"```md\n[fenced](../8)\n```\n\n" +
+		"* [Include three](../3?L)\n* [Include four](../4)\n\n" +
+		"[^1]: Notes [here](../5).\n"
+
+	for _, l := range kegml.ParseLinks(doc) {
+		fmt.Printf("%q %q %q %q %v\n", l.Text, l.ID, l.File, l.Query, l.Include)
+	}
+
+	fmt.Println(kegml.ParseIncludeIDs(doc))
+
+	// Output:
+	// "the zero node" "0" "" "" false
+	// "a\nfile" "" "data.csv" "T" false
+	// "a site" "" "" "" false
+	// "Include three" "3" "" "L" true
+	// "Include four" "4" "" "" true
+	// "here" "5" "" "" false
+	// [3 4]
+}
+
+func ExampleParseBlocks() {
+
+	doc := "# Some title\n\nA paragraph\nwith two lines.\n\n" +
+		"* [Include](../1)\n* [Another](../2?T)\n\n" +
+		"* a bullet\n* another\n\n1. one\n2. two\n\n----\n\n" +
+		"```go\nfmt.Println(`hi`)\n\n```\n" +
+		"$$\nx^2\n$$\n\n> quoted\n\n![fig](img.png)\n\n| a | b |\n\n" +
+		":::note\nstuff\n:::\n\n[^1]: a note\n"
+
+	blocks, err := kegml.ParseBlocks(doc)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, b := range blocks.Under {
+		fmt.Printf("%v %v-%v %q %q\n", b.Name(), b.B, b.E, b.V, b.Capture())
+	}
+
+	// Output:
+	// TitleB 0-12 "Some title" "Some title"
+	// ParaB 14-41 "" "A paragraph\nwith two lines."
+	// IncListB 43-80 "" "* [Include](../1)\n* [Another](../2?T)"
+	// BulListB 82-102 "" "* a bullet\n* another"
+	// NumListB 104-117 "" "1. one\n2. two"
+	// Separator 119-123 "" "----"
+	// FencedB 125-153 "go" "fmt.Println(`hi`)\n"
+	// LatexB 154-163 "" "x^2"
+	// QuoteB 165-173 "" "> quoted"
+	// FigureB 175-190 "" "![fig](img.png)"
+	// TableB 192-201 "" "| a | b |"
+	// DivB 203-220 "note" "stuff"
+	// NoteB 222-234 "" "[^1]: a note"
+}
+
+func ExampleParseBlocks_errors() {
+
+	_, err := kegml.ParseBlocks("No title here\n")
+	fmt.Println(err)
+
+	blocks, err := kegml.ParseBlocks("# Title\n\n```\nnever closed\n")
+	fmt.Println(err)
+	fmt.Printf("%q\n", blocks.Under[1].Capture())
+
+	// Output:
+	// 1:1: missing title
+	// 3:1: unclosed FencedB block
+	// "never closed\n"
+}
+
+func ExampleParseBlocks_crlf() {
+
+	doc := "# Title\r\n\r\n```go \r\ncode\r\n``` \r\n" +
+		"----\r\n\r\n$$\r\nx^2\r\n$$\r\n"
+
+	blocks, err := kegml.ParseBlocks(doc)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, b := range blocks.Under {
+		fmt.Printf("%v %q %q\n", b.Name(), b.V, b.Capture())
+	}
+
+	// Output:
+	// TitleB "Title" "Title"
+	// FencedB "go" "code"
+	// Separator "" "----"
+	// LatexB "" "x^2"
+}
+
+func ExampleParseBlocks_sample() {
+
+	blocks, err := kegml.ParseBlocks(must(os.ReadFile(`../testdata/samplekeg/1/README.md`)))
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, b := range blocks.Under {
+		fmt.Print(b.Name(), " ")
+	}
+
+	// Output:
+	// TitleB ParaB TableB BulListB TableB BulListB ParaB TableB FencedB NoteB
+}
+
+func ExampleParseSpans() {
+
+	spans := kegml.ParseSpans("***Lede*** with *inflect **beacon***, `code`, $x^2$,\n" +
+		"<https://example.com>, ~~gone~~, [link *text*](../2?T), note[^1] and \\*escaped\\*.")
+
+	for _, s := range spans {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// {"T":"Lede","N":[{"T":"Plain","V":"Lede"}]}
+	// {"T":"Plain","V":" with "}
+	// {"T":"Inflect","N":[{"T":"Plain","V":"inflect "},{"T":"Beacon","N":[{"T":"Plain","V":"beacon"}]}]}
+	// {"T":"Plain","V":", "}
+	// {"T":"Code","V":"code"}
+	// {"T":"Plain","V":", "}
+	// {"T":"Math","V":"x^2"}
+	// {"T":"Plain","V":",\n"}
+	// {"T":"URL","V":"https://example.com"}
+	// {"T":"Plain","V":", "}
+	// {"T":"Deleted","N":[{"T":"Plain","V":"gone"}]}
+	// {"T":"Plain","V":", "}
+	// {"T":"Link","V":"../2?T","N":[{"T":"Plain","V":"link "},{"T":"Inflect","N":[{"T":"Plain","V":"text"}]}]}
+	// {"T":"Plain","V":", note"}
+	// {"T":"FootRef","V":"1"}
+	// {"T":"Plain","V":" and *escaped*."}
+}
+
+func ExampleParseSpans_footRef() {
+
+	// labels may not contain white space, backslashes, or backticks
+	spans := kegml.ParseSpans("a[^1] b[^x y] c[^x\\y] d[^`x`] e[^] f[^ok-2]")
+
+	for _, s := range spans {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// {"T":"Plain","V":"a"}
+	// {"T":"FootRef","V":"1"}
+	// {"T":"Plain","V":" b[^x y] c[^x\\y] d[^"}
+	// {"T":"Code","V":"x"}
+	// {"T":"Plain","V":"] e[^] f"}
+	// {"T":"FootRef","V":"ok-2"}
+}
+
+func ExampleParse() {
+
+	doc := "# Title with `code`\n\nPara *one*.\n\n" +
+		"* [Inc *one*](../1)\n\n* item one\n  continued\n- item two\n\n" +
+		"> quote *this*\n> and more\n\n| A | *B* |\n|-|:-:|\n| `a|b` | c |\n\n" +
+		"[^1]: The *note*.\n"
+
+	node, err := kegml.Parse(doc)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, n := range node.Under {
+		fmt.Println(n)
+	}
+
+	// the offsets are always within the original
+	item := node.Under[3].Under[0]
+	fmt.Printf("%q %q\n", item.Text(), item.Capture())
+
+	// Output:
+	// {"T":"Title","V":"Title with `code`","N":[{"T":"Plain","V":"Title with "},{"T":"Code","V":"code"}]}
+	// {"T":"Paragraph","N":[{"T":"Plain","V":"Para "},{"T":"Inflect","N":[{"T":"Plain","V":"one"}]},{"T":"Plain","V":"."}]}
+	// {"T":"Includes","N":[{"T":"Include","V":"../1","N":[{"T":"Plain","V":"Inc "},{"T":"Inflect","N":[{"T":"Plain","V":"one"}]}]}]}
+	// {"T":"Bulleted","N":[{"T":"Item","V":"*","N":[{"T":"Plain","V":"item one\ncontinued"}]},{"T":"Item","V":"-","N":[{"T":"Plain","V":"item two"}]}]}
+	// {"T":"Quote","N":[{"T":"Plain","V":"quote "},{"T":"Inflect","N":[{"T":"Plain","V":"this"}]},{"T":"Plain","V":"\nand more"}]}
+	// {"T":"Table","N":[{"T":"Row","N":[{"T":"Cell","N":[{"T":"Plain","V":"A"}]},{"T":"Cell","N":[{"T":"Inflect","N":[{"T":"Plain","V":"B"}]}]}]},{"T":"Delim","N":[{"T":"Cell","V":"-"},{"T":"Cell","V":":-:"}]},{"T":"Row","N":[{"T":"Cell","N":[{"T":"Code","V":"a|b"}]},{"T":"Cell","N":[{"T":"Plain","V":"c"}]}]}]}
+	// {"T":"Footnotes","N":[{"T":"Footnote","V":"1","N":[{"T":"Plain","V":"The "},{"T":"Inflect","N":[{"T":"Plain","V":"note"}]},{"T":"Plain","V":"."}]}]}
+	// "* item one\n  continued" "item one\n  continued"
+}
+
+func ExampleParse_positions() {
+
+	doc := "# Title\n\nSome *para*\nwith [a link](../3).\n\n* one\n* two `x`\n"
+
+	node, _ := kegml.Parse(doc)
+	node.Walk(func(n *ast.Node) bool {
+		fmt.Printf("%v %v-%v\n", n.Name(), n.Pos, n.End)
+		return true
+	})
+
+	// Output:
+	// Document 1:1-8:1
+	// Title 1:1-1:8
+	// Plain 1:3-1:8
+	// Paragraph 3:1-4:21
+	// Plain 3:1-3:6
+	// Inflect 3:6-3:12
+	// Plain 3:7-3:11
+	// Plain 3:12-4:6
+	// Link 4:6-4:20
+	// Plain 4:7-4:13
+	// Plain 4:20-4:21
+	// Bulleted 6:1-7:10
+	// Item 6:1-6:6
+	// Plain 6:3-6:6
+	// Item 7:1-7:10
+	// Plain 7:3-7:7
+	// Code 7:7-7:10
+}
+
+func ExampleLint() {
+
+	doc := "Not a title\n\n# Title\n\n* one\n\n1. two\n\n----\n\n----\n\n" +
+		"Some ***lede*** late.\n\n[^1]: note\n\n> quote\n\n[^2]: again\n\n```\nopen"
+
+	for _, d := range kegml.Lint(doc) {
+		fmt.Println(d)
+	}
+
+	// Output:
+	// 1:1: title must be first line
+	// 3:1: title must be first line
+	// 7:1: list must not follow list
+	// 11:1: separator must not follow separator
+	// 13:6: lede must be first span of paragraph
+	// 15:1: footnotes must be last block
+	// 19:1: only one footnotes block allowed
+	// 19:1: footnotes must be last block
+	// 21:1: unclosed FencedB block
+}
+
+func ExampleLint_blank() {
+
+	fmt.Println(kegml.Lint("\n\n# Title\n\nSome text.\n"))
+
+	// Output:
+	// [3:1: title must be first line]
+}
+
+func ExampleLint_sample() {
+
+	fmt.Println(kegml.Lint(must(os.ReadFile(`../testdata/samplekeg/1/README.md`))))
+
+	// Output:
+	// []
+}
+
+func ExampleFix() {
+
+	doc := "# Title  \n\n[^1]: a note\n\n* one\n- two\n+ three\n\n" +
+		"----\n\n----\n\nSome text.\n* [Included](../1)\n"
+
+	fixed, diags := kegml.Fix(doc)
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+	fmt.Print(string(fixed))
+
+	fmt.Println(kegml.Lint(fixed))
+
+	// Output:
+	// 1:1: removed trailing white space from title
+	// 3:1: moved footnotes to end
+	// 6:1: changed bullet "-" to "*"
+	// 7:1: changed bullet "+" to "*"
+	// 11:1: removed separator following separator
+	// 14:1: added blank line before include list
+	// # Title
+	//
+	// * one
+	// * two
+	// * three
+	//
+	// ----
+	//
+	// Some text.
+	//
+	// * [Included](../1)
+	//
+	// [^1]: a note
+	// []
+}
+
+func ExampleFix_includes() {
+
+	doc := "# Title\n* [One](../1)\n\n* a\n- b\n- [Two](../2)\n\n" +
+		"1. first\n* [Three](../3)\n\n```\ncode\n```\n* [Four](../4)\n"
+
+	fixed, diags := kegml.Fix(doc)
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+	fmt.Print(string(fixed))
+
+	// Output:
+	// 2:1: added blank line before include list
+	// 5:1: changed bullet "-" to "*"
+	// 6:1: added blank line before include list
+	// 9:1: added blank line before include list
+	// 14:1: added blank line before include list
+	// # Title
+	//
+	// * [One](../1)
+	//
+	// * a
+	// * b
+	//
+	// - [Two](../2)
+	//
+	// 1. first
+	//
+	// * [Three](../3)
+	//
+	// ```
+	// code
+	// ```
+	//
+	// * [Four](../4)
+}
+
+func ExampleFix_leadingNotes() {
+
+	doc := "[^1]: a note\n\n# Title\n\nSome text[^1].\n"
+
+	fixed, diags := kegml.Fix(doc)
+	fmt.Println(diags)
+	fmt.Print(string(fixed))
+
+	again, diags := kegml.Fix(fixed)
+	fmt.Println(string(again) == string(fixed), diags)
+
+	// Output:
+	// [1:1: moved footnotes to end]
+	// # Title
+	//
+	// Some text[^1].
+	//
+	// [^1]: a note
+	// true []
+}
+
+func ExampleFix_none() {
+
+	buf := must(os.ReadFile(`../testdata/samplekeg/1/README.md`))
+	fixed, diags := kegml.Fix(buf)
+	fmt.Println(string(fixed) == string(buf), diags)
+
+	// Output:
+	// true []
+}
+
+func ExampleFormat() {
+
+	doc := "\n# A Title  \r\n\r\n\r\nSee this[^b] and that[^a].   \n\n" +
+		"- one\n+ two\n  - nested\n\n" +
+		"[^a]: the a note\n\n" +
+		"|Name|Value|\n|:-|-:|\n|x|a longer value|\n\n" +
+		"```go\nfunc  main() {\n}\n```\n\n" +
+		"[^b]: the b note\n[^c]: never used\n\n\n"
+
+	out, err := kegml.Format(doc)
+	fmt.Println(err)
+	fmt.Print(string(out))
+
+	again, _ := kegml.Format(out)
+	fmt.Println(string(again) == string(out))
+
+	// Output:
+	// <nil>
+	// # A Title
+	//
+	// See this[^1] and that[^2].
+	//
+	// * one
+	// * two
+	//   * nested
+	//
+	// | Name | Value          |
+	// | :--- | -------------: |
+	// | x    | a longer value |
+	//
+	// ```go
+	// func  main() {
+	// }
+	// ```
+	//
+	// [^1]: the b note
+	// [^2]: the a note
+	// [^3]: never used
+	// true
+}
+
+func ExampleFormat_division() {
+
+	out, _ := kegml.Format("# T\n\nSee[^b].\n\n:::note\nAlso[^b] and[^a].\n\n" +
+		"[^c]: C.\n:::\n\n[^a]: A.\n[^b]: B.\n")
+	fmt.Print(string(out))
+
+	// Output:
+	// # T
+	//
+	// See[^1].
+	//
+	// :::note
+	// Also[^1] and[^2].
+	//
+	// [^3]: C.
+	// :::
+	//
+	// [^1]: B.
+	// [^2]: A.
+}
+
+func ExampleFormat_error() {
+	out, err := kegml.Format("# Title\n\n```\nunclosed\n")
+	fmt.Println(out, err)
+
+	// Output:
+	// [] 3:1: unclosed FencedB block
+}
+
+func ExamplePlainText() {
+
+	fmt.Println(kegml.PlainText("# A *Fine* Title\n\n" +
+		"Some **bold** and [a link](../2) with `code`[^1].\n\n" +
+		"* [Included](../3)\n\n" +
+		"| A | B |\n|---|---|\n| 1 | 2 |\n\n" +
+		"```go\nfmt.Println()\n```\n\n" +
+		"[^1]: A *note*."))
+
+	// Output:
+	// A Fine Title
+	//
+	// Some bold and a link with code.
+	//
+	// Included
+	//
+	// A B
+	// 1 2
+	//
+	// fmt.Println()
+	//
+	// A note.
+}
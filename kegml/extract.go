@@ -0,0 +1,68 @@
+package kegml
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+var (
+	trailingSpace = regexp.MustCompile(`[ \t]+\n`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+)
+
+// PlainText parses the KEGML document (see Parse for input types) and
+// returns only its text content without any markup at all: no tokens,
+// link targets, footnote references, or table delimiters. Link and
+// figure text, code, math, and the content of fenced blocks are all
+// kept since they are content. Blocks are separated by a blank line and
+// list items, table rows, and footnotes by line returns. Lines never
+// end with white space. This is for indexing and searching content, not
+// for display.
+func PlainText(in any) string {
+	doc, _ := Parse(in)
+	var s strings.Builder
+	plainText(&s, doc)
+	text := trailingSpace.ReplaceAllString(s.String(), "\n")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+func plainText(s *strings.Builder, n *ast.Node) {
+	switch n.T {
+
+	case ast.Plain, ast.Code, ast.Math, ast.URL:
+		s.WriteString(n.V)
+		return
+
+	case ast.FootRef, ast.Separator, ast.Delim:
+		return
+
+	case ast.Fenced:
+		s.WriteString(n.Capture())
+
+	case ast.Latex:
+		s.WriteString(n.V)
+
+	case ast.Division:
+		s.WriteString(PlainText(n.Capture()))
+
+	}
+
+	for _, u := range n.Under {
+		plainText(s, u)
+	}
+
+	switch n.T {
+	case ast.Item, ast.Include, ast.Row, ast.Footnote:
+		s.WriteString("\n")
+	case ast.Cell:
+		s.WriteString(" ")
+	case ast.Document, ast.Blocks:
+	default:
+		if !ast.IsSpan(n.T) {
+			s.WriteString("\n\n")
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package kegml
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+// Fix parses the KEGML document (see ParseBlocks for input types) and
+// returns it with every violation that can be fixed mechanically fixed
+// along with a Diagnostic (at the original position) for every change
+// made:
+//
+//     * Trailing white space is removed from titles
+//     * Separators following separators are removed
+//     * Footnotes blocks are merged and moved to the end
+//     * Bulleted list items are changed to use the first item's bullet
+//     * Include lists get a blank line before them if they directly
+//       follow any other block (or end one, such as a paragraph)
+//
+// Everything else (including the white space between blocks) is left
+// exactly as it was. Include lists are never changed since their
+// bullets have meaning. If nothing needed fixing the original is
+// returned with an empty slice. Those that cannot be fixed are still
+// reported by Lint.
+func Fix(in any) ([]byte, []Diagnostic) {
+	diags := []Diagnostic{}
+	blocks, _ := ParseBlocks(in)
+	r := blocks.R
+
+	var out strings.Builder
+	var notes []string
+	var prev *ast.Node
+	end := 0
+
+	for i, b := range blocks.Under {
+		gap := string(r[end:b.B])
+		text := b.Text()
+		end = b.E
+
+		switch b.T {
+
+		case ast.TitleB:
+			if trim := strings.TrimRight(text, " \t"); trim != text {
+				text = trim
+				diags = append(diags, diagnose(b, _FixTitle))
+			}
+
+		case ast.Separator:
+			if prev != nil && prev.T == ast.Separator {
+				diags = append(diags, diagnose(b, _FixSepSep))
+				continue
+			}
+
+		case ast.NoteB:
+			notes = append(notes, text)
+			if len(notes) > 1 || i < len(blocks.Under)-1 {
+				diags = append(diags, diagnose(b, _FixNotes))
+			}
+			continue
+
+		case ast.ParaB, ast.BulListB, ast.NumListB, ast.QuoteB,
+			ast.TableB, ast.FigureB:
+			text = fixIncludes(b, &diags)
+
+		}
+
+		// keep only what came before the first block of the document
+		// (not the footnotes skipped before the first written)
+		if out.Len() == 0 {
+			gap = string(r[:blocks.Under[0].B])
+		}
+
+		// single line blocks (title, separator) and those ending with
+		// a token line (fenced) can be directly followed by another
+		if b.T == ast.IncListB && out.Len() > 0 && strings.Count(gap, "\n") < 2 {
+			nl := "\n"
+			if strings.HasSuffix(gap, "\r\n") {
+				nl = "\r\n"
+			}
+			gap += nl
+			diags = append(diags, diagnose(b, _FixIncBlank))
+		}
+
+		out.WriteString(gap)
+		out.WriteString(text)
+		prev = b
+	}
+
+	if len(diags) == 0 {
+		return []byte(string(r)), diags
+	}
+
+	if notes != nil {
+		if out.Len() > 0 {
+			out.WriteString("\n\n")
+		}
+		out.WriteString(strings.Join(notes, "\n"))
+	}
+	out.WriteString(string(r[end:]))
+
+	return []byte(out.String()), diags
+}
+
+// fixBullets returns the lines of a bulleted list beginning at the
+// given line (of the document) joined with the bullet of every item
+// changed to that of the first.
+func fixBullets(lines []string, first int, diags *[]Diagnostic) string {
+	want := lines[0][:1]
+	for i, line := range lines {
+		m := bulletTok.FindStringSubmatch(line)
+		if m == nil || m[1] == want {
+			continue
+		}
+		lines[i] = want + line[1:]
+		pos := ast.Position{Line: first + i, Col: 1}
+		*diags = append(*diags, Diagnostic{
+			Pos: pos, End: pos, Msg: fmt.Sprintf(_FixBullet, m[1], want),
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fixIncludes returns the text of the block with a blank line added
+// before any include list lines ending it (and the bullets of the rest
+// of a bulleted list fixed, see fixBullets, but never those of the
+// include list).
+func fixIncludes(b *ast.Node, diags *[]Diagnostic) string {
+	lines := strings.Split(b.Text(), "\n")
+	first := len(lines)
+	for first > 0 &&
+		includeLine.MatchString(strings.TrimSuffix(lines[first-1], "\r")) {
+		first--
+	}
+	if first == 0 {
+		return b.Text()
+	}
+	text := strings.Join(lines[:first], "\n")
+	if b.T == ast.BulListB {
+		text = fixBullets(lines[:first], b.Pos.Line, diags)
+	}
+	if first == len(lines) {
+		return text
+	}
+	pos := ast.Position{Line: b.Pos.Line + first, Col: 1}
+	*diags = append(*diags, Diagnostic{Pos: pos, End: pos, Msg: _FixIncBlank})
+	nl := "\n"
+	if strings.HasSuffix(lines[first-1], "\r") {
+		nl = "\r\n"
+	}
+	return text + "\n" + nl + strings.Join(lines[first:], "\n")
+}
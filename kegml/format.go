@@ -0,0 +1,236 @@
+package kegml
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+var nestedBullet = regexp.MustCompile(`^(\s*)[+-] `)
+
+// sub is a single replacement of the source runes from b to e.
+type sub struct {
+	b, e int
+	with string
+}
+
+// Format parses the KEGML document (see Parse for input types) and
+// returns it in its one canonical form so that the same content is
+// always written the same way no matter the editor used:
+//
+//     * Blocks are separated by exactly one blank line
+//     * Lines never end with white space and always with a line feed
+//     * Bulleted list bullets (but not include list) are all stars (*)
+//     * Footnotes are merged into one block at the end, numbered and
+//       sorted by the order of their first reference
+//     * Table pipes (|) are aligned with delimiter dashes to match
+//     * Fenced, division, and math blocks are kept byte for byte (but
+//       for footnote labels within divisions)
+//
+// Like gofmt, nothing is returned but the first error if the document
+// cannot be parsed (see Parse) since formatting it would only make
+// things worse.
+func Format(in any) ([]byte, error) {
+	doc, err := Parse(in)
+	if err != nil {
+		return nil, err
+	}
+	r := doc.R
+
+	var notes []*ast.Node
+	var blocks []*ast.Node
+	for _, n := range doc.Under {
+		if n.T == ast.Footnotes {
+			notes = append(notes, n.Under...)
+			continue
+		}
+		blocks = append(blocks, n)
+	}
+
+	// number labels by first reference (in the body, including within
+	// divisions, then the footnotes) and then any left unreferenced in
+	// the order they appear (with those within divisions last)
+	number := map[string]int{}
+	num := func(label string) int {
+		if _, has := number[label]; !has {
+			number[label] = len(number) + 1
+		}
+		return number[label]
+	}
+	var subs []sub
+	var divnotes []sub // label of footnotes within divisions
+	var renumber func(n *ast.Node, at int)
+	renumber = func(n *ast.Node, at int) {
+		n.Walk(func(u *ast.Node) bool {
+			switch u.T {
+			case ast.FootRef:
+				subs = append(subs, sub{at + u.B, at + u.E, "[^" + strconv.Itoa(num(u.V)) + "]"})
+				return false
+			case ast.Footnote:
+				if at > 0 {
+					label := len([]rune("[^" + u.V + "]"))
+					divnotes = append(divnotes, sub{at + u.B, at + u.B + label, u.V})
+				}
+			case ast.Division:
+				div, _ := Parse(u.Capture())
+				renumber(div, at+u.XB)
+				return false
+			}
+			return true
+		})
+	}
+	for _, n := range blocks {
+		renumber(n, 0)
+	}
+	for _, n := range notes {
+		renumber(n, 0)
+	}
+	for _, n := range notes {
+		num(n.V)
+	}
+	for _, x := range divnotes {
+		subs = append(subs, sub{x.b, x.e, "[^" + strconv.Itoa(num(x.with)) + "]"})
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].b < subs[j].b })
+
+	var out []string
+	for _, n := range blocks {
+		switch n.T {
+
+		case ast.Title:
+			out = append(out, `# `+strings.TrimSpace(rewrite(r, n.XB, n.XE, subs)))
+
+		case ast.Separator:
+			out = append(out, `----`)
+
+		case ast.Fenced, ast.Latex:
+			out = append(out, n.Text())
+
+		case ast.Division:
+			out = append(out, rewrite(r, n.B, n.E, subs))
+
+		case ast.Table:
+			out = append(out, table(r, n, subs))
+
+		case ast.Bulleted:
+			lines := trimLines(rewrite(r, n.B, n.E, subs))
+			for i, line := range lines {
+				lines[i] = nestedBullet.ReplaceAllString(line, `$1* `)
+			}
+			out = append(out, strings.Join(lines, "\n"))
+
+		default:
+			out = append(out, strings.Join(trimLines(rewrite(r, n.B, n.E, subs)), "\n"))
+
+		}
+	}
+
+	if len(notes) > 0 {
+		sort.SliceStable(notes, func(i, j int) bool {
+			return number[notes[i].V] < number[notes[j].V]
+		})
+		lines := make([]string, 0, len(notes))
+		for _, n := range notes {
+			text := strings.Join(trimLines(rewrite(r, n.XB, n.E, subs)), "\n")
+			note := "[^" + strconv.Itoa(number[n.V]) + "]: " + text
+			lines = append(lines, strings.TrimRight(note, " "))
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+
+	return []byte(strings.Join(out, "\n\n") + "\n"), nil
+}
+
+// rewrite returns the source runes from b to e with every sub within
+// them replaced. The subs must be sorted.
+func rewrite(r []rune, b, e int, subs []sub) string {
+	var s strings.Builder
+	at := b
+	for _, x := range subs {
+		if x.b < at || x.e > e {
+			continue
+		}
+		s.WriteString(string(r[at:x.b]))
+		s.WriteString(x.with)
+		at = x.e
+	}
+	s.WriteString(string(r[at:e]))
+	return s.String()
+}
+
+// trimLines splits text into lines with all trailing white space
+// (including any carriage return) removed.
+func trimLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return lines
+}
+
+// table returns the table with every cell padded so that the pipes of
+// every row line up and the delimiter dashes fill each column.
+func table(r []rune, n *ast.Node, subs []sub) string {
+	rows := make([][]string, len(n.Under))
+	var widths []int
+	for i, row := range n.Under {
+		for j, c := range row.Under {
+			cell := strings.TrimSpace(rewrite(r, c.XB, c.XE, subs))
+			rows[i] = append(rows[i], cell)
+			if j >= len(widths) {
+				widths = append(widths, 3)
+			}
+			if l := len([]rune(cell)); row.T == ast.Row && l > widths[j] {
+				widths[j] = l
+			}
+		}
+	}
+
+	if len(widths) == 0 {
+		widths = append(widths, 3)
+	}
+
+	lines := make([]string, len(rows))
+	for i, cells := range rows {
+		delim := n.Under[i].T == ast.Delim
+		padded := make([]string, len(widths))
+		for j, w := range widths {
+			var cell string
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			if delim {
+				padded[j] = dashes(cell, w)
+				continue
+			}
+			padded[j] = cell + strings.Repeat(" ", w-len([]rune(cell)))
+		}
+		lines[i] = `| ` + strings.Join(padded, ` | `) + ` |`
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dashes returns a delimiter cell w runes wide keeping the alignment
+// colons of the original.
+func dashes(cell string, w int) string {
+	left := strings.HasPrefix(cell, `:`)
+	right := strings.HasSuffix(cell, `:`) && len(cell) > 1
+	n := w
+	if left {
+		n--
+	}
+	if right {
+		n--
+	}
+	d := strings.Repeat(`-`, n)
+	if left {
+		d = `:` + d
+	}
+	if right {
+		d += `:`
+	}
+	return d
+}
@@ -0,0 +1,116 @@
+package html_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rwxrob/keg/kegml"
+	"github.com/rwxrob/keg/kegml/html"
+)
+
+func ExampleRender() {
+
+	doc, _ := kegml.Parse("# A *Fine* Title\n\n" +
+		"***Lede here.*** Then *some* **bold** ~~gone~~ `a<b` $x^2$[^n].\n\n" +
+		"* [Included](../3?T)\n\n" +
+		"1. See [zero](../0) and <https://example.com>\n\n" +
+		"```go\nfmt.Println(\"<hi>\")\n```\n\n" +
+		"$$\n\\sum x\n$$\n\n" +
+		"| A | B |\n|:--|--:|\n| 1 | 2 |\n\n" +
+		"[^n]: A note.")
+
+	html.Render(os.Stdout, doc)
+
+	// Output:
+	// <h1>A <i>Fine</i> Title</h1>
+	// <p class="lede"><span class="lede">Lede here.</span> Then <i>some</i> <b>bold</b> <del>gone</del> <code>a&lt;b</code> <span class="math">\(x^2\)</span><sup id="fnref-1"><a href="#fn-1">1</a></sup>.</p>
+	// <ul class="includes">
+	// <li><a href="../3">Included</a></li>
+	// </ul>
+	// <ol>
+	// <li>See <a href="../0">zero</a> and <a href="https://example.com">https://example.com</a></li>
+	// </ol>
+	// <pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>
+	// <div class="math">\[\sum x\]</div>
+	// <table>
+	// <thead>
+	// <tr><th style="text-align:left">A</th><th style="text-align:right">B</th></tr>
+	// </thead>
+	// <tbody>
+	// <tr><td style="text-align:left">1</td><td style="text-align:right">2</td></tr>
+	// </tbody>
+	// </table>
+	// <section class="footnotes">
+	// <ol>
+	// <li id="fn-1" value="1">A note. <a href="#fnref-1">↩</a></li>
+	// </ol>
+	// </section>
+}
+
+func ExampleRenderer_Render() {
+
+	doc, _ := kegml.Parse("# Title\n\nSee [node 2](../2) and ***this***.\n")
+
+	r := html.Renderer{
+		NodeURL:   func(id string) string { return "/keg/" + id + "/" },
+		LedeClass: "lead",
+	}
+	r.Render(os.Stdout, doc)
+
+	// Output:
+	// <h1>Title</h1>
+	// <p>See <a href="/keg/2/">node 2</a> and <span class="lead">this</span>.</p>
+}
+
+func ExampleRender_unsafe() {
+
+	doc, _ := kegml.Parse("# Title\n\n[x](javascript:alert%281%29) [y](JavaScript:x) " +
+		"[z](data:text/html,hi) <vbscript:x> [ok](HTTPS://example.com) " +
+		"[rel](a/b:c) [mail](mailto:me@example.com)\n\n![pic](javascript:x)\n")
+
+	html.Render(os.Stdout, doc)
+
+	// Output:
+	// <h1>Title</h1>
+	// <p><a href="#">x</a> <a href="#">y</a> <a href="#">z</a> <a href="#">vbscript:x</a> <a href="HTTPS://example.com">ok</a> <a href="a/b:c">rel</a> <a href="mailto:me@example.com">mail</a></p>
+	// <figure><img src="#" alt="pic"><figcaption>pic</figcaption></figure>
+}
+
+func ExampleRender_division() {
+
+	doc, _ := kegml.Parse("# Title\n\nOutside[^a].\n\n" +
+		":::note\nInside[^b].\n\n[^b]: Inner note.\n:::\n\n[^a]: Outer note.\n")
+
+	html.Render(os.Stdout, doc)
+
+	// Output:
+	// <h1>Title</h1>
+	// <p>Outside<sup id="fnref-1"><a href="#fn-1">1</a></sup>.</p>
+	// <div class="note">
+	// <p>Inside<sup id="fnref-2"><a href="#fn-2">2</a></sup>.</p>
+	// </div>
+	// <section class="footnotes">
+	// <ol>
+	// <li id="fn-1" value="1">Outer note. <a href="#fnref-1">↩</a></li>
+	// <li id="fn-2" value="2">Inner note. <a href="#fnref-2">↩</a></li>
+	// </ol>
+	// </section>
+}
+
+func ExampleRender_sample() {
+
+	buf, _ := os.ReadFile(`../../testdata/samplekeg/1/README.md`)
+	doc, _ := kegml.Parse(buf)
+	var out countWriter
+	fmt.Println(html.Render(&out, doc), out > 0)
+
+	// Output:
+	// <nil> true
+}
+
+type countWriter int
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	*c += countWriter(len(b))
+	return len(b), nil
+}
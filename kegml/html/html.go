@@ -0,0 +1,425 @@
+/*
+Package html renders a parsed KEGML document (see kegml.Parse) into an
+HTML5 fragment suitable for placing within the body of a web page. Every
+KEGML semantic is kept:
+
+    * Inflect and Beacon become <i> and <b> (the same semantics)
+    * Lede becomes a lead paragraph with a class for styling
+    * Fenced blocks become <pre><code> with a language-* class
+    * Math ($ and $$) is delimited for MathJax (\( \) and \[ \])
+    * Footnotes are numbered and moved to a section at the end
+    * Node links (../N) become whatever URL is wanted (see NodeURL)
+    * Links to anything but SafeSchemes (javascript: ...) become #
+
+Use a Renderer to change the defaults.
+*/
+package html
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml"
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+var nodeTarget = regexp.MustCompile(`^\.\./(\d+)/?(?:\?.*)?$`)
+
+// SafeSchemes are the only URL schemes (lowercase) kept in links,
+// autolinks, and figures. Any other (javascript:, data:, ...) is
+// replaced with # so that rendered HTML is safe to publish. Relative
+// URLs (no scheme) are always kept.
+var SafeSchemes = map[string]bool{`http`: true, `https`: true, `mailto`: true}
+
+// Renderer renders ast.Node trees (from kegml.Parse) as HTML5.
+type Renderer struct {
+
+	// NodeURL returns the URL for a node link to the given node ID.
+	// If nil, the original ../N is used (without any query code).
+	NodeURL func(id string) string
+
+	// LedeClass is the class attribute value of lede paragraphs and
+	// spans. If empty, "lede" is used.
+	LedeClass string
+}
+
+// Render renders n with a Renderer with every default (see
+// Renderer.Render).
+func Render(w io.Writer, n *ast.Node) error { return new(Renderer).Render(w, n) }
+
+// Render writes the HTML5 for n (usually an ast.Document from
+// kegml.Parse but any semantic or span node will do) to w returning
+// the first error writing. The footnotes of a document are always
+// rendered last in a <section class="footnotes"> numbered in the order
+// of their first reference no matter where they are in the document.
+// Division blocks are rendered as a <div> with the class set to the
+// division attributes and the content parsed and rendered within it.
+func (r *Renderer) Render(w io.Writer, n *ast.Node) error {
+	s := &state{
+		Renderer: r, w: w,
+		number: map[string]int{}, refs: map[string]int{},
+		divs: map[*ast.Node]*ast.Node{},
+	}
+	var notes []*ast.Node
+	s.collect(n, &notes)
+	for _, note := range notes {
+		note.Walk(func(u *ast.Node) bool {
+			if u.T == ast.FootRef {
+				s.num(u.V)
+			}
+			return true
+		})
+	}
+	for _, note := range notes {
+		s.num(note.V)
+	}
+
+	s.node(n)
+
+	if len(notes) > 0 {
+		s.footnotes(notes)
+	}
+	return s.err
+}
+
+// state holds everything needed during a single Render.
+type state struct {
+	*Renderer
+	w      io.Writer
+	err    error
+	number map[string]int          // footnote number by label
+	refs   map[string]int          // footnote references so far by label
+	divs   map[*ast.Node]*ast.Node // parsed content by Division
+}
+
+// collect numbers every footnote reference under n in order and adds
+// the footnotes of every Footnotes block to notes including those
+// within divisions.
+func (s *state) collect(n *ast.Node, notes *[]*ast.Node) {
+	n.Walk(func(u *ast.Node) bool {
+		switch u.T {
+		case ast.FootRef:
+			s.num(u.V)
+		case ast.Footnotes:
+			*notes = append(*notes, u.Under...)
+			return false
+		case ast.Division:
+			s.collect(s.division(u), notes)
+			return false
+		}
+		return true
+	})
+}
+
+// division returns the parsed content of the Division (parsed once).
+func (s *state) division(n *ast.Node) *ast.Node {
+	doc, has := s.divs[n]
+	if !has {
+		doc, _ = kegml.Parse(n.Capture())
+		s.divs[n] = doc
+	}
+	return doc
+}
+
+// num returns the footnote number of label assigning the next if new.
+func (s *state) num(label string) int {
+	if _, has := s.number[label]; !has {
+		s.number[label] = len(s.number) + 1
+	}
+	return s.number[label]
+}
+
+// print writes the strings to w unless a previous write failed.
+func (s *state) print(a ...string) {
+	for _, str := range a {
+		if s.err != nil {
+			return
+		}
+		_, s.err = io.WriteString(s.w, str)
+	}
+}
+
+func (s *state) lede() string {
+	if s.LedeClass == "" {
+		return `lede`
+	}
+	return s.LedeClass
+}
+
+// url returns the URL for the link target mapping node links (see
+// safe).
+func (s *state) url(target string) string {
+	m := nodeTarget.FindStringSubmatch(target)
+	switch {
+	case m == nil:
+		return safe(target)
+	case s.NodeURL != nil:
+		return s.NodeURL(m[1])
+	}
+	return `../` + m[1]
+}
+
+// safe returns the target unchanged if relative or with one of the
+// SafeSchemes and # otherwise.
+func safe(target string) string {
+	t := strings.TrimSpace(target)
+	i := strings.IndexAny(t, `:/?#`)
+	if i < 0 || t[i] != ':' || SafeSchemes[strings.ToLower(t[:i])] {
+		return target
+	}
+	return `#`
+}
+
+// spans renders every node under n.
+func (s *state) spans(n *ast.Node) {
+	for _, u := range n.Under {
+		s.node(u)
+	}
+}
+
+func (s *state) node(n *ast.Node) {
+	esc := html.EscapeString
+
+	switch n.T {
+
+	case ast.Document:
+		for _, u := range n.Under {
+			if u.T == ast.Footnotes {
+				continue
+			}
+			s.node(u)
+		}
+
+	case ast.Title:
+		s.print(`<h1>`)
+		s.spans(n)
+		s.print("</h1>\n")
+
+	case ast.Separator:
+		s.print("<hr>\n")
+
+	case ast.Includes:
+		s.print("<ul class=\"includes\">\n")
+		for _, u := range n.Under {
+			s.print(`<li><a href="`, esc(s.url(u.V)), `">`)
+			s.spans(u)
+			s.print("</a></li>\n")
+		}
+		s.print("</ul>\n")
+
+	case ast.Bulleted:
+		s.print("<ul>\n")
+		s.spans(n)
+		s.print("</ul>\n")
+
+	case ast.Numbered:
+		s.print("<ol")
+		if len(n.Under) > 0 && n.Under[0].V != "1" {
+			start, _ := strconv.Atoi(n.Under[0].V)
+			s.print(fmt.Sprintf(` start="%v"`, start))
+		}
+		s.print(">\n")
+		s.spans(n)
+		s.print("</ol>\n")
+
+	case ast.Item:
+		s.print(`<li>`)
+		s.spans(n)
+		s.print("</li>\n")
+
+	case ast.Figure:
+		s.print(`<figure><img src="`, esc(safe(n.V)), `" alt="`, esc(plain(n)), `">`)
+		if len(n.Under) > 0 {
+			s.print(`<figcaption>`)
+			s.spans(n)
+			s.print(`</figcaption>`)
+		}
+		s.print("</figure>\n")
+
+	case ast.Quote:
+		s.print(`<blockquote><p>`)
+		s.spans(n)
+		s.print("</p></blockquote>\n")
+
+	case ast.Latex:
+		s.print(`<div class="math">\[`, esc(n.V), "\\]</div>\n")
+
+	case ast.Fenced:
+		s.print(`<pre><code`)
+		if lang := strings.Fields(n.V); len(lang) > 0 {
+			s.print(` class="language-`, esc(strings.Trim(lang[0], `{}.`)), `"`)
+		}
+		s.print(`>`, esc(n.Capture()), "</code></pre>\n")
+
+	case ast.Division:
+		s.print(`<div`)
+		if class := strings.Trim(n.V, `{} `); class != "" {
+			s.print(` class="`, esc(strings.ReplaceAll(class, `.`, ``)), `"`)
+		}
+		s.print(">\n")
+		s.node(s.division(n))
+		s.print("</div>\n")
+
+	case ast.Table:
+		s.table(n)
+
+	case ast.Paragraph:
+		if len(n.Under) > 0 && n.Under[0].T == ast.Lede {
+			s.print(`<p class="`, esc(s.lede()), `">`)
+		} else {
+			s.print(`<p>`)
+		}
+		s.spans(n)
+		s.print("</p>\n")
+
+	case ast.Plain:
+		s.print(esc(n.V))
+
+	case ast.Inflect:
+		s.print(`<i>`)
+		s.spans(n)
+		s.print(`</i>`)
+
+	case ast.Beacon:
+		s.print(`<b>`)
+		s.spans(n)
+		s.print(`</b>`)
+
+	case ast.Lede:
+		s.print(`<span class="`, esc(s.lede()), `">`)
+		s.spans(n)
+		s.print(`</span>`)
+
+	case ast.Deleted:
+		s.print(`<del>`)
+		s.spans(n)
+		s.print(`</del>`)
+
+	case ast.Math:
+		s.print(`<span class="math">\(`, esc(n.V), `\)</span>`)
+
+	case ast.Code:
+		s.print(`<code>`, esc(n.V), `</code>`)
+
+	case ast.URL:
+		href := n.V
+		if !strings.Contains(href, `:`) {
+			href = `mailto:` + href
+		}
+		href = safe(href)
+		s.print(`<a href="`, esc(href), `">`, esc(n.V), `</a>`)
+
+	case ast.Link:
+		s.print(`<a href="`, esc(s.url(n.V)), `">`)
+		s.spans(n)
+		s.print(`</a>`)
+
+	case ast.FootRef:
+		s.refs[n.V]++
+		num := strconv.Itoa(s.num(n.V))
+		id := `fnref-` + num
+		if s.refs[n.V] > 1 {
+			id += `-` + strconv.Itoa(s.refs[n.V])
+		}
+		s.print(`<sup id="`, id, `"><a href="#fn-`, num, `">`, num, `</a></sup>`)
+
+	}
+}
+
+// table renders the table with a <thead> for the rows before the
+// delimiter row (if any) and the alignment of every cell from it.
+func (s *state) table(n *ast.Node) {
+	var align []string
+	head := 0
+	for i, row := range n.Under {
+		if row.T != ast.Delim {
+			continue
+		}
+		head = i
+		for _, c := range row.Under {
+			l, r := strings.HasPrefix(c.V, `:`), strings.HasSuffix(c.V, `:`)
+			switch {
+			case l && r:
+				align = append(align, `center`)
+			case r:
+				align = append(align, `right`)
+			case l:
+				align = append(align, `left`)
+			default:
+				align = append(align, ``)
+			}
+		}
+		break
+	}
+
+	cells := func(row *ast.Node, tag string) {
+		s.print(`<tr>`)
+		for i, c := range row.Under {
+			s.print(`<`, tag)
+			if i < len(align) && align[i] != "" {
+				s.print(` style="text-align:`, align[i], `"`)
+			}
+			s.print(`>`)
+			s.spans(c)
+			s.print(`</`, tag, `>`)
+		}
+		s.print("</tr>\n")
+	}
+
+	s.print("<table>\n")
+	if head > 0 {
+		s.print("<thead>\n")
+		for _, row := range n.Under[:head] {
+			cells(row, `th`)
+		}
+		s.print("</thead>\n")
+	}
+	s.print("<tbody>\n")
+	for _, row := range n.Under[head:] {
+		if row.T == ast.Delim {
+			continue
+		}
+		cells(row, `td`)
+	}
+	s.print("</tbody>\n</table>\n")
+}
+
+// footnotes renders the footnotes in order of their number each with
+// a link back to the first reference.
+func (s *state) footnotes(notes []*ast.Node) {
+	sorted := make([]*ast.Node, len(notes))
+	copy(sorted, notes)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && s.num(sorted[j].V) < s.num(sorted[j-1].V); j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	s.print("<section class=\"footnotes\">\n<ol>\n")
+	for _, note := range sorted {
+		num := strconv.Itoa(s.num(note.V))
+		s.print(`<li id="fn-`, num, `" value="`, num, `">`)
+		s.spans(note)
+		if s.refs[note.V] > 0 {
+			s.print(` <a href="#fnref-`, num, `">↩</a>`)
+		}
+		s.print("</li>\n")
+	}
+	s.print("</ol>\n</section>\n")
+}
+
+// plain returns the text of every Plain, Code, and Math node under n.
+func plain(n *ast.Node) string {
+	var text strings.Builder
+	n.Walk(func(u *ast.Node) bool {
+		switch u.T {
+		case ast.Plain, ast.Code, ast.Math:
+			text.WriteString(u.V)
+		}
+		return true
+	})
+	return text.String()
+}
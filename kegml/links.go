@@ -0,0 +1,201 @@
+package kegml
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+// Link is a single KEGML link ([text](target)) found within a document.
+// Links within fenced and math blocks and within code spans are never
+// links. Images (figures) and footnote references are not links.
+//
+// There are three types of links: node, file, and footnote. Node links
+// always begin with ../ followed by the node ID (ex: ../3). File links
+// are always local to the same directory as the README.md (no slash).
+// Both may have a query code that begins with a question mark (?).
+// Anything else (URLs, absolute paths, index nodes like ../dex) is
+// kept as Target only.
+type Link struct {
+	Text    string // between the brackets
+	Target  string // between the parenthesis
+	ID      string // if node link (../3)
+	File    string // if file link (somefile)
+	Query   string // query code without the question mark
+	Include bool   // if from an include list block
+}
+
+var (
+	nodeTarget  = regexp.MustCompile(`^\.\./(\d+)/?$`)
+	includeLine = regexp.MustCompile(`^[*+-] \[.*\]\([^()\s]+\)\s*$`)
+)
+
+// ParseLinks returns every Link found in the KEGML document passed as
+// any of the following:
+//
+// * string
+// * []byte
+// * []rune
+// * io.Reader
+//
+// Links are returned in the order they appear (including duplicates).
+// Link text may span more than one line but never a blank one.
+func ParseLinks(in any) []Link {
+	links := []Link{}
+	blocks, _ := ParseBlocks(in)
+	for _, block := range blocks.Under {
+		switch block.T {
+		case ast.FencedB, ast.LatexB:
+			continue
+		}
+		for _, l := range scanLinks(block.Capture()) {
+			l.Include = block.T == ast.IncListB
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// ParseIncludeIDs returns all the identifiers parsed from the node
+// include links of any include list block. Include list blocks contain
+// nothing but lines beginning with a star (*), plus (+), or dash (-)
+// followed by a single space and a link.
+//
+//     * [Title to 3](../3)
+//     * [Title to another](../4?T)
+//
+// The ID is drawn from the node link target and must be a valid integer
+// following exactly two periods and a single slash. Always returns
+// a slice even if empty.
+func ParseIncludeIDs(in any) []string {
+	ids := []string{}
+	for _, l := range ParseLinks(in) {
+		if l.Include && l.ID != "" {
+			ids = append(ids, l.ID)
+		}
+	}
+	return ids
+}
+
+func isIncludeList(block []string) bool {
+	for _, line := range block {
+		if !includeLine.MatchString(line) {
+			return false
+		}
+	}
+	return len(block) > 0
+}
+
+// scanLinks returns the links found in a single block of text skipping
+// code spans, images, and footnote references.
+func scanLinks(text string) []Link {
+	var links []Link
+	r := []rune(text)
+	for i := 0; i < len(r); i++ {
+		switch r[i] {
+
+		case '`':
+			n := runLen(r, i, '`')
+			i = skipCode(r, i, n)
+
+		case '[':
+			if i > 0 && r[i-1] == '!' {
+				continue
+			}
+			if i+1 < len(r) && r[i+1] == '^' {
+				continue
+			}
+			end := closeBracket(r, i)
+			if end < 0 || end+1 >= len(r) || r[end+1] != '(' {
+				continue
+			}
+			tend := closeParen(r, end+1)
+			if tend < 0 {
+				continue
+			}
+			links = append(links, newLink(
+				string(r[i+1:end]), string(r[end+2:tend]),
+			))
+			i = tend
+		}
+	}
+	return links
+}
+
+func newLink(text, target string) Link {
+	l := Link{Text: text, Target: target}
+	path, query, _ := strings.Cut(target, `?`)
+	l.Query = query
+	switch {
+	case nodeTarget.MatchString(path):
+		l.ID = nodeTarget.FindStringSubmatch(path)[1]
+	case path == "",
+		strings.HasPrefix(path, `../`),
+		strings.HasPrefix(path, `/`),
+		strings.HasPrefix(path, `#`),
+		strings.Contains(path, `:`):
+	default:
+		l.File = path
+	}
+	return l
+}
+
+// runLen returns the number of consecutive runes matching c beginning
+// at i.
+func runLen(r []rune, i int, c rune) int {
+	n := 0
+	for i+n < len(r) && r[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// skipCode returns the index of the last backtick closing the code span
+// opened by n backticks at i, or the last of the opening backticks if
+// never closed.
+func skipCode(r []rune, i, n int) int {
+	for j := i + n; j < len(r); j++ {
+		if r[j] != '`' {
+			continue
+		}
+		m := runLen(r, j, '`')
+		if m == n {
+			return j + m - 1
+		}
+		j += m - 1
+	}
+	return i + n - 1
+}
+
+// closeBracket returns the index of the right bracket matching the left
+// one at i (allowing nested brackets) or -1.
+func closeBracket(r []rune, i int) int {
+	depth := 0
+	for j := i; j < len(r); j++ {
+		switch r[j] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// closeParen returns the index of the right parenthesis closing the
+// link target beginning at i or -1 if whitespace is found first.
+func closeParen(r []rune, i int) int {
+	for j := i + 1; j < len(r); j++ {
+		switch r[j] {
+		case ')':
+			return j
+		case ' ', '\t', '\n':
+			return -1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,126 @@
+package kegml
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+// Diagnostic is a single problem found in a KEGML document (see Lint).
+type Diagnostic struct {
+	File string       // optional, set by those linting files
+	Pos  ast.Position // beginning of the problem
+	End  ast.Position // ending of the problem
+	Msg  string       // description
+}
+
+// String fulfills the fmt.Stringer interface in the form common to
+// compilers and linters (file:line:col: message) so that editors can
+// jump directly to the problem. The file is omitted if empty.
+func (d Diagnostic) String() string {
+	if d.File == "" {
+		return fmt.Sprintf("%v: %v", d.Pos, d.Msg)
+	}
+	return fmt.Sprintf("%v:%v: %v", d.File, d.Pos, d.Msg)
+}
+
+func diagnose(n *ast.Node, msg string, args ...any) Diagnostic {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	return Diagnostic{Pos: n.Pos, End: n.End, Msg: msg}
+}
+
+func isList(t int) bool {
+	return t == ast.Includes || t == ast.Bulleted || t == ast.Numbered
+}
+
+// Lint parses the KEGML document (see Parse for input types) and
+// returns a Diagnostic for every parse error and every violation of the
+// following structural rules of the KEGML specification:
+//
+//     * Title must be first line and not exceed 72 total runes
+//     * Only a single Title or Footnotes block is allowed
+//     * Footnotes must be the last block
+//     * Lists must never follow other Lists of any type
+//     * Separator must never follow another Separator block
+//     * Lede must be first (and possibly only) span in paragraph block
+//
+// Diagnostics are in the order they appear in the document. An empty
+// slice is returned if there are no problems.
+func Lint(in any) []Diagnostic {
+	diags := []Diagnostic{}
+
+	doc, err := Parse(in)
+	if e, is := err.(Error); is && e.Msg != _MissingTitle {
+		diags = append(diags, Diagnostic{Pos: e.Pos, End: e.Pos, Msg: e.Msg})
+	}
+
+	var titles, notes int
+	var prev *ast.Node
+	for i, n := range doc.Under {
+
+		switch n.T {
+
+		case ast.Title:
+			titles++
+			switch {
+			case titles > 1:
+				diags = append(diags, diagnose(n, _TitleOnce))
+			case n.B > 0: // even if only blank lines before
+				diags = append(diags, diagnose(n, _TitleFirst))
+			}
+			if l := len(n.R[n.B:n.E]); l > 72 {
+				diags = append(diags, diagnose(n, _TitleLong, l))
+			}
+
+		case ast.Footnotes:
+			notes++
+			if notes > 1 {
+				diags = append(diags, diagnose(n, _NotesOnce))
+			}
+
+		case ast.Separator:
+			if prev != nil && prev.T == ast.Separator {
+				diags = append(diags, diagnose(n, _SepSep))
+			}
+
+		}
+
+		if i == 0 && n.T != ast.Title {
+			diags = append(diags, diagnose(n, _TitleFirst))
+		}
+
+		if prev != nil && prev.T == ast.Footnotes && n.T != ast.Footnotes {
+			diags = append(diags, diagnose(prev, _NotesLast))
+		}
+
+		if prev != nil && isList(prev.T) && isList(n.T) {
+			diags = append(diags, diagnose(n, _ListList))
+		}
+
+		n.Walk(func(u *ast.Node) bool {
+			if u.T != ast.Lede {
+				return true
+			}
+			if n.T != ast.Paragraph || len(n.Under) == 0 || n.Under[0] != u {
+				diags = append(diags, diagnose(u, _LedeFirst))
+			}
+			return true
+		})
+
+		prev = n
+	}
+
+	if len(doc.Under) == 0 {
+		diags = append(diags, diagnose(doc, _TitleFirst))
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Pos, diags[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+
+	return diags
+}
@@ -0,0 +1,264 @@
+package kegml
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+var (
+	bulletTok = regexp.MustCompile(`^([*+-]) `)
+	numberTok = regexp.MustCompile(`^(\d{1,8})\. `)
+	noteTok   = regexp.MustCompile(`^\[\^([^\]\s]+)\]:[ \t]*`)
+	delimCell = regexp.MustCompile(`^\s*:?-+:?\s*$`)
+)
+
+// Parse parses a KEGML document (see ParseBlocks for input types) into
+// a semantic abstract syntax tree (the Node rule of kegml.pegn) with an
+// ast.Document node at the root. This is the second pass that divides
+// each block from ParseBlocks into its semantic parts:
+//
+//        BLOCK      │ SEMANTIC NODE   │ UNDER
+//     ──────────────┼─────────────────┼──────────────────────────────
+//       TitleB      │ Title           │ Spans (V is title)
+//       IncListB    │ Includes        │ Include (V is target), Spans
+//       Separator   │ Separator       │
+//       BulListB    │ Bulleted        │ Item (V is bullet), Spans
+//       NumListB    │ Numbered        │ Item (V is number), Spans
+//       FigureB     │ Figure          │ Spans (V is target)
+//       QuoteB      │ Quote           │ Spans
+//       LatexB      │ Latex           │ (V is math)
+//       FencedB     │ Fenced          │ (V is language)
+//       DivB        │ Division        │ (V is attributes)
+//       TableB      │ Table           │ Row or Delim, Cell, Spans
+//       NoteB       │ Footnotes       │ Footnote (V is label), Spans
+//       ParaB       │ Paragraph       │ Spans
+//
+// Spans within quotes, list items, and table cells are parsed from the
+// content without tokens, indentation, or cell delimiters but every
+// node still reports its offsets (B, E, XB, XE) within the original
+// runes (R) and the line and column of each (Pos, End). See ParseSpans
+// for the span types.
+//
+// Like ParseBlocks, the tree is always returned along with the first
+// error encountered. No structural rules are enforced (see Lint).
+func Parse(in any) (*ast.Node, error) {
+	blocks, err := ParseBlocks(in)
+	r := blocks.R
+	doc := &ast.Node{T: ast.Document, R: r, B: blocks.B, E: blocks.E, XB: blocks.XB, XE: blocks.XE}
+	lines := splitLines(r)
+	for _, b := range blocks.Under {
+		doc.Add(semantic(b, r, linesWithin(lines, b.B, b.E)))
+	}
+	doc.SetPositions()
+	return doc, err
+}
+
+// linesWithin returns the spans of the lines from b to e.
+func linesWithin(lines []span, b, e int) []span {
+	var within []span
+	for _, l := range lines {
+		if l.b >= b && l.e <= e {
+			within = append(within, l)
+		}
+	}
+	return within
+}
+
+// semantic converts a single block into its semantic node.
+func semantic(b *ast.Node, r []rune, lines []span) *ast.Node {
+	n := &ast.Node{R: r, B: b.B, E: b.E, XB: b.XB, XE: b.XE, V: b.V}
+
+	switch b.T {
+
+	case ast.TitleB:
+		n.T = ast.Title
+		n.Under = spansOf(r, b.XB, b.XE)
+
+	case ast.Separator:
+		n.T = ast.Separator
+
+	case ast.LatexB:
+		n.T = ast.Latex
+		n.V = b.Capture()
+
+	case ast.FencedB:
+		n.T = ast.Fenced
+
+	case ast.DivB:
+		n.T = ast.Division
+
+	case ast.ParaB:
+		n.T = ast.Paragraph
+		n.Under = spansOf(r, b.B, b.E)
+
+	case ast.QuoteB:
+		n.T = ast.Quote
+		t := &text{src: r}
+		for i, l := range lines {
+			if i > 0 {
+				t.newline(lines[i-1].e)
+			}
+			line := string(r[l.b:l.e])
+			skip := 0
+			switch {
+			case strings.HasPrefix(line, `> `):
+				skip = 2
+			case strings.HasPrefix(line, `>`):
+				skip = 1
+			}
+			t.add(l.b+skip, l.e)
+		}
+		n.Under = t.spans(0, len(t.r))
+
+	case ast.FigureB:
+		n.T = ast.Figure
+		t := &text{src: r}
+		t.add(b.B, b.E)
+		if link, _ := t.link(1, len(t.r)); link != nil {
+			n.V = link.V
+			n.XB, n.XE = link.XB, link.XE
+			n.Under = link.Under
+		}
+
+	case ast.IncListB:
+		n.T = ast.Includes
+		t := &text{src: r}
+		t.add(b.B, b.E)
+		for _, l := range lines {
+			i := l.b - b.B + 2
+			item := &ast.Node{T: ast.Include, R: r, B: l.b, E: l.e}
+			if link, _ := t.link(i, l.e-b.B); link != nil {
+				item.V = link.V
+				item.XB, item.XE = link.XB, link.XE
+				item.Under = link.Under
+			}
+			n.Add(item)
+		}
+
+	case ast.BulListB:
+		n.T = ast.Bulleted
+		n.Under = items(r, lines, bulletTok, ast.Item)
+
+	case ast.NumListB:
+		n.T = ast.Numbered
+		n.Under = items(r, lines, numberTok, ast.Item)
+
+	case ast.NoteB:
+		n.T = ast.Footnotes
+		n.Under = items(r, lines, noteTok, ast.Footnote)
+
+	case ast.TableB:
+		n.T = ast.Table
+		for _, l := range lines {
+			n.Add(row(r, l))
+		}
+
+	}
+
+	return n
+}
+
+// spansOf returns the spans of the contiguous source runes from b to e.
+func spansOf(r []rune, b, e int) []*ast.Node {
+	t := &text{src: r}
+	t.add(b, e)
+	return t.spans(0, len(t.r))
+}
+
+// items divides the lines into items each beginning with a line
+// matching tok (the first submatch of which becomes V) followed by any
+// lines that do not (with their indentation removed). The spans of each
+// item are parsed from everything following the token.
+func items(r []rune, lines []span, tok *regexp.Regexp, typ int) []*ast.Node {
+	var list []*ast.Node
+	var cur *ast.Node
+	var t *text
+
+	done := func() {
+		if cur != nil {
+			cur.Under = t.spans(0, len(t.r))
+			list = append(list, cur)
+		}
+	}
+
+	for _, l := range lines {
+		line := string(r[l.b:l.e])
+		if m := tok.FindStringSubmatch(line); m != nil {
+			done()
+			skip := len([]rune(m[0]))
+			cur = &ast.Node{T: typ, R: r, B: l.b, E: l.e, XB: l.b + skip, XE: l.e, V: m[1]}
+			t = &text{src: r}
+			t.add(l.b+skip, l.e)
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		indent := len([]rune(line)) - len([]rune(strings.TrimLeft(line, " \t")))
+		t.newline(cur.E)
+		t.add(l.b+indent, l.e)
+		cur.E, cur.XE = l.e, l.e
+	}
+	done()
+
+	return list
+}
+
+// row divides a single table line into cells on every pipe (|) that is
+// not escaped or within a code span returning a Delim if every cell
+// contains nothing but dashes (and optional alignment colons) or a Row
+// otherwise. The V of each Delim cell is its trimmed content.
+func row(r []rune, l span) *ast.Node {
+	n := &ast.Node{T: ast.Row, R: r, B: l.b, E: l.e, XB: l.b, XE: l.e}
+
+	var bounds []int
+	for i := l.b; i < l.e; i++ {
+		switch r[i] {
+		case '\\':
+			i++
+		case '`':
+			i = skipCode(r[:l.e], i, runLen(r[:l.e], i, '`'))
+		case '|':
+			bounds = append(bounds, i)
+		}
+	}
+	if len(bounds) == 0 ||
+		strings.TrimSpace(string(r[bounds[len(bounds)-1]+1:l.e])) != "" {
+		bounds = append(bounds, l.e)
+	}
+
+	delim := true
+	start := l.b
+	if len(bounds) > 0 && bounds[0] == l.b {
+		start = l.b + 1
+		bounds = bounds[1:]
+	}
+	for _, end := range bounds {
+		cell := &ast.Node{T: ast.Cell, R: r, B: start, E: end}
+		raw := string(r[start:end])
+		lead := len([]rune(raw)) - len([]rune(strings.TrimLeft(raw, " \t")))
+		trail := len([]rune(raw)) - len([]rune(strings.TrimRight(raw, " \t")))
+		cell.XB, cell.XE = start+lead, end-trail
+		if cell.XB > cell.XE {
+			cell.XB = cell.XE
+		}
+		if !delimCell.MatchString(raw) {
+			delim = false
+		}
+		cell.Under = spansOf(r, cell.XB, cell.XE)
+		n.Add(cell)
+		start = end + 1
+	}
+
+	if delim && len(n.Under) > 0 {
+		n.T = ast.Delim
+		for _, c := range n.Under {
+			c.V = c.Capture()
+			c.Under = nil
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,361 @@
+package kegml
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rwxrob/keg/kegml/ast"
+)
+
+var footLabel = regexp.MustCompile("^\\^[^\\]\\s\\\\`]+$")
+
+// text is a sequence of runes drawn from one or more (possibly
+// non-contiguous) parts of the source runes (src) with idx holding the
+// offset within src of every rune in r. This allows spans to be parsed
+// from the content of quotes, list items, and table cells (without
+// their tokens and indentation) while every ast.Node still refers to
+// its true offsets within the source.
+type text struct {
+	src  []rune
+	r    []rune
+	idx  []int
+	fail map[bound]int // first opener of each pair found unclosed
+	deep int           // runes parsed looking for nested spans (see pair)
+}
+
+// bound identifies the pair tokens searched for a closing run up to
+// the same end (non-inclusive).
+type bound struct {
+	tok string
+	end int
+}
+
+// add appends the source runes from b to e (non-inclusive).
+func (t *text) add(b, e int) {
+	for i := b; i < e; i++ {
+		t.r = append(t.r, t.src[i])
+		t.idx = append(t.idx, i)
+	}
+}
+
+// newline appends a line feed rune for the line ending at offset at.
+func (t *text) newline(at int) {
+	t.r = append(t.r, '\n')
+	t.idx = append(t.idx, at)
+}
+
+// node returns a new ast.Node of type typ matching the runes of the
+// text from a to b (non-inclusive) with the capture set to the same.
+func (t *text) node(typ, a, b int) *ast.Node {
+	n := &ast.Node{T: typ, R: t.src}
+	n.B, n.E = t.offsets(a, b)
+	n.XB, n.XE = n.B, n.E
+	return n
+}
+
+// offsets converts the range a to b (non-inclusive) of the text into
+// a range of source offsets.
+func (t *text) offsets(a, b int) (int, int) {
+	switch {
+	case len(t.idx) == 0:
+		return 0, 0
+	case a >= len(t.idx):
+		e := t.idx[len(t.idx)-1] + 1
+		return e, e
+	case b <= a:
+		return t.idx[a], t.idx[a]
+	}
+	return t.idx[a], t.idx[b-1] + 1
+}
+
+// ParseSpans parses the spans (Inflect, Beacon, Lede, Math, Code, URL,
+// Deleted, Link, FootRef, and Plain) from any of the following
+// returning them as ast.Node values with their offsets (and line and
+// column positions) relative to the beginning of the input:
+//
+// * string
+// * []byte
+// * []rune
+// * io.Reader
+//
+//       SPAN      │  TOKENS    │  CONTAINS
+//     ────────────┼────────────┼────────────────────
+//       Lede      │ ***        │ Spans
+//       Beacon    │ **         │ Spans
+//       Inflect   │ *          │ Spans
+//       Deleted   │ ~~         │ Spans
+//       Math      │ $          │ Runes
+//       Code      │ `          │ Runes
+//       URL       │ < >        │ Runes
+//       Link      │ [ ]( )     │ Spans (V is target)
+//       FootRef   │ [^ ]       │ Runes (V is label)
+//       Plain     │ (none)     │ Runes
+//
+// Opening tokens must not be followed by white space and closing tokens
+// must not follow it. Footnote reference labels must not contain white
+// space, backslashes, or backticks. Anything that cannot be closed is
+// Plain. The value (V) of Plain spans has any backslash escapes
+// removed. Line returns remain in Plain spans as is.
+func ParseSpans(in any) []*ast.Node {
+	r := []rune(stringify(in))
+	t := &text{src: r}
+	t.add(0, len(r))
+	spans := t.spans(0, len(t.r))
+	lines := ast.NewLines(r)
+	for _, s := range spans {
+		s.Walk(func(n *ast.Node) bool {
+			n.Pos, n.End = lines.Position(n.B), lines.Position(n.E)
+			return true
+		})
+	}
+	return spans
+}
+
+// spans parses the spans of the text from a to b (non-inclusive).
+func (t *text) spans(a, b int) []*ast.Node {
+	var nodes []*ast.Node
+	r := t.r
+	plain := a
+	var plainv []rune
+
+	flush := func(end int) {
+		if end > plain {
+			n := t.node(ast.Plain, plain, end)
+			n.V = string(plainv)
+			nodes = append(nodes, n)
+		}
+		plainv = plainv[:0]
+	}
+
+	for i := a; i < b; {
+		var n *ast.Node
+		var next int
+
+		switch r[i] {
+
+		case '\\':
+			if i+1 < b && unicode.IsPunct(r[i+1]) || i+1 < b && unicode.IsSymbol(r[i+1]) {
+				plainv = append(plainv, r[i+1])
+				i += 2
+				continue
+			}
+
+		case '`':
+			n, next = t.code(i, b)
+
+		case '$':
+			n, next = t.leaf(ast.Math, i, b, '$', '$')
+
+		case '<':
+			n, next = t.leaf(ast.URL, i, b, '<', '>')
+			if n != nil && !strings.ContainsAny(n.V, `:@`) {
+				n = nil
+			}
+
+		case '*':
+			switch runLen(r[:b], i, '*') {
+			case 1:
+				n, next = t.pair(ast.Inflect, i, b, `*`)
+			case 2:
+				n, next = t.pair(ast.Beacon, i, b, `**`)
+			case 3:
+				n, next = t.pair(ast.Lede, i, b, `***`)
+			}
+
+		case '~':
+			if runLen(r[:b], i, '~') == 2 {
+				n, next = t.pair(ast.Deleted, i, b, `~~`)
+			}
+
+		case '[':
+			if i > a && r[i-1] == '!' {
+				break
+			}
+			if i+1 < b && r[i+1] == '^' {
+				n, next = t.leaf(ast.FootRef, i, b, '[', ']')
+				if n != nil && !footLabel.MatchString(n.V) {
+					n = nil
+				}
+				if n != nil {
+					n.V = strings.TrimPrefix(n.V, `^`)
+					n.XB = t.idx[i+2]
+				}
+				break
+			}
+			n, next = t.link(i, b)
+
+		}
+
+		if n == nil {
+			// unclosed token runs are plain in their entirety
+			run := 1
+			switch r[i] {
+			case '`', '*', '~':
+				run = runLen(r[:b], i, r[i])
+			}
+			plainv = append(plainv, r[i:i+run]...)
+			i += run
+			continue
+		}
+
+		flush(i)
+		nodes = append(nodes, n)
+		i = next
+		plain = i
+	}
+	flush(b)
+
+	return nodes
+}
+
+// code returns a Code span beginning at i (with any number of
+// backticks) or nil if never closed by the same number of backticks.
+func (t *text) code(i, b int) (*ast.Node, int) {
+	r := t.r[:b]
+	n := runLen(r, i, '`')
+	for j := i + n; j < b; j++ {
+		if r[j] != '`' {
+			continue
+		}
+		m := runLen(r, j, '`')
+		if m == n {
+			node := t.node(ast.Code, i, j+m)
+			node.XB, node.XE = t.offsets(i+n, j)
+			node.V = string(r[i+n : j])
+			if len(node.V) > 1 && node.V[0] == ' ' && node.V[len(node.V)-1] == ' ' {
+				node.V = node.V[1 : len(node.V)-1]
+			}
+			return node, j + m
+		}
+		j += m - 1
+	}
+	return nil, 0
+}
+
+// leaf returns a span of type typ that begins with open at i and ends
+// with the first close rune on the same line with no white space
+// following open or preceding close. V is set to the runes between.
+func (t *text) leaf(typ, i, b int, open, close rune) (*ast.Node, int) {
+	r := t.r
+	if i+1 >= b || unicode.IsSpace(r[i+1]) || r[i+1] == close {
+		return nil, 0
+	}
+	for j := i + 1; j < b; j++ {
+		switch {
+		case r[j] == '\n':
+			return nil, 0
+		case r[j] == '\\':
+			j++
+		case r[j] == close:
+			if unicode.IsSpace(r[j-1]) {
+				return nil, 0
+			}
+			n := t.node(typ, i, j+1)
+			n.XB, n.XE = t.offsets(i+1, j)
+			n.V = string(r[i+1 : j])
+			return n, j + 1
+		}
+	}
+	return nil, 0
+}
+
+// pair returns a span of type typ beginning with the tok at i and
+// ending with the first exact same run of tok runes (not part of
+// a longer run) that is not preceded by white space. Everything between
+// is parsed as spans.
+//
+// To keep parsing linear a pair that cannot be closed before b is
+// never searched for again from any later opener of the same tok. A
+// longer run is only checked for closing a nested span when an inner
+// run that might open one is still unclosed and no more than sixteen
+// times the runes of the text have been parsed checking others (which
+// only happens with pathological input).
+func (t *text) pair(typ, i, b int, tok string) (*ast.Node, int) {
+	r := t.r[:b]
+	c := rune(tok[0])
+	n := len(tok)
+	if i+n >= b || unicode.IsSpace(r[i+n]) {
+		return nil, 0
+	}
+	key := bound{tok, b}
+	if f, has := t.fail[key]; has && f <= i {
+		return nil, 0
+	}
+	var open uint // bit m set for every unclosed inner run of m runes
+	from := i     // first opener no longer worth looking from
+	for j := i + n; j < b; j++ {
+		switch {
+		case r[j] == '\\':
+			j++
+			continue
+		case r[j] == '`':
+			if _, next := t.code(j, b); next > 0 {
+				j = next - 1
+				from = next
+			} else {
+				j += runLen(r, j, '`') - 1
+			}
+			continue
+		case r[j] != c:
+			continue
+		}
+		m := runLen(r, j, c)
+		closing := !unicode.IsSpace(r[j-1])
+		if closing && m == n {
+			node := t.node(typ, i, j+n)
+			node.XB, node.XE = t.offsets(i+n, j)
+			node.Under = t.spans(i+n, j)
+			return node, j + n
+		}
+		// a longer run closes this one with its last runes only if the
+		// runes before them close a span nested within (*a **b***)
+		if closing && m > n && open&(1<<(m-n+1)-1) != 0 && t.deep < 16*len(t.r) {
+			end := j + m - n
+			t.deep += end - i - n
+			under := t.spans(i+n, end)
+			if last := len(under) - 1; last >= 0 && under[last].T != ast.Plain &&
+				under[last].E == t.idx[end-1]+1 {
+				node := t.node(typ, i, end+n)
+				node.XB, node.XE = t.offsets(i+n, end)
+				node.Under = under
+				return node, end + n
+			}
+		}
+		if m < 4 {
+			switch {
+			case closing && open&(1<<m) != 0:
+				open &^= 1 << m
+			case j+m < b && !unicode.IsSpace(r[j+m]):
+				open |= 1 << m
+			}
+		}
+		j += m - 1
+	}
+	if t.fail == nil {
+		t.fail = map[bound]int{}
+	}
+	if f, has := t.fail[key]; !has || from < f {
+		t.fail[key] = from
+	}
+	return nil, 0
+}
+
+// link returns a Link span ([text](target)) beginning at i with V set
+// to the target and the link text parsed as spans.
+func (t *text) link(i, b int) (*ast.Node, int) {
+	r := t.r[:b]
+	end := closeBracket(r, i)
+	if end < 0 || end+1 >= b || r[end+1] != '(' {
+		return nil, 0
+	}
+	tend := closeParen(r, end+1)
+	if tend < 0 {
+		return nil, 0
+	}
+	n := t.node(ast.Link, i, tend+1)
+	n.XB, n.XE = t.offsets(i+1, end)
+	n.V = string(r[end+2 : tend])
+	n.Under = t.spans(i+1, end)
+	return n, tend + 1
+}
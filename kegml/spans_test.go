@@ -0,0 +1,26 @@
+package kegml
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSpans_unclosed(t *testing.T) {
+
+	// every opener looking for a closing run that never comes (or
+	// a nested span to close) must not search the rest of the text again
+	for _, unit := range []string{
+		`*a**`, `**a*`, `*a *`, `~~a~~~`, `*a $**x$ y***`, "*a `*` b**",
+	} {
+		in := strings.Repeat(unit, 4096)
+		start := time.Now()
+		spans := ParseSpans(in)
+		if len(spans) == 0 || spans[len(spans)-1].E != len([]rune(in)) {
+			t.Errorf(`%q: spans do not cover the input`, unit)
+		}
+		if took := time.Since(start); took > 500*time.Millisecond {
+			t.Errorf(`%q: took too long: %v`, unit, took)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package kegml
+
+const (
+	_MissingTitle = `missing title`
+	_Unclosed     = `unclosed %v block`
+	_TitleFirst   = `title must be first line`
+	_TitleOnce    = `only one title allowed`
+	_TitleLong    = `title must not exceed 72 runes (has %v)`
+	_NotesOnce    = `only one footnotes block allowed`
+	_NotesLast    = `footnotes must be last block`
+	_ListList     = `list must not follow list`
+	_SepSep       = `separator must not follow separator`
+	_LedeFirst    = `lede must be first span of paragraph`
+	_FixTitle     = `removed trailing white space from title`
+	_FixSepSep    = `removed separator following separator`
+	_FixNotes     = `moved footnotes to end`
+	_FixBullet    = `changed bullet %q to %q`
+	_FixIncBlank  = `added blank line before include list`
+)
@@ -11,7 +11,6 @@ import (
 	"unicode"
 
 	"github.com/rwxrob/keg/kegml"
-	"github.com/rwxrob/keg/scan"
 )
 
 const (
@@ -47,6 +46,19 @@ const (
 //
 // Includes is guaranteed to always return a slice even if empty.
 //
+// Links
+//
+// Links contains the IDs of every node linked to from anywhere within
+// the node content (including those also in Includes) each appearing
+// only once in the order first found. Links are not persisted in an
+// index file and are therefore only set by ReadNode (see ScanIndex).
+//
+// Files
+//
+// Files contains the names of every local file linked to from within
+// the node content each appearing only once. Like Links, Files are only
+// set by ReadNode.
+//
 // Note that it is perfectly acceptable and expected that
 // implementations of Node cast these Node interface instances back into
 // specific private struct implementations within package scope to
@@ -74,6 +86,8 @@ type Node struct {
 	Title    string
 	Changed  time.Time
 	Includes []string
+	Links    []string
+	Files    []string
 }
 
 // IntID converts the ID into a proper integer (usually using
@@ -126,11 +140,12 @@ func NewNodeFromLine[T string | []byte | []rune](line T) *Node {
 }
 
 // ReadNode reads a node from the README.md file within the passed
-// dirpath. The last modification time is used as the Created time. The
+// dirpath. The last modification time is used as the Changed time. The
 // title is parsed from the first line (maximum of 72 runes including
 // the hastag and space). The file is then scanned for any include
 // blocks and if found their node ids are added to the Includes slice.
-// Never returns nil.
+// Every other node link and file link is also added to the Links and
+// Files slices (see kegml.ParseLinks). Never returns nil.
 func ReadNode(dirpath string) (*Node, error) {
 	node := new(Node)
 	file := filepath.Join(dirpath, `README.md`)
@@ -140,18 +155,27 @@ func ReadNode(dirpath string) (*Node, error) {
 		return node, err
 	}
 
-	// we don't need the overhead of a full AST parse
-	s := scan.New(buf)
+	// we don't need the overhead of a full AST parse (and the links are
+	// only parsed once for Includes, Links, and Files)
+	node.Title = ParseTitle(buf)
+	node.Includes = []string{}
 
-	if title := kegml.ParseTitle(s); title != nil {
-		node.Title = title.V
-	}
-
-	if ids := kegml.ParseIncludeIDs(s); ids != nil {
-		node.Includes = ids.Join(",")
+	seen := map[string]bool{}
+	for _, l := range kegml.ParseLinks(buf) {
+		if l.Include && l.ID != "" {
+			node.Includes = append(node.Includes, l.ID)
+		}
+		switch {
+		case l.ID != "" && !seen[`../`+l.ID]:
+			seen[`../`+l.ID] = true
+			node.Links = append(node.Links, l.ID)
+		case l.File != "" && !seen[l.File]:
+			seen[l.File] = true
+			node.Files = append(node.Files, l.File)
+		}
 	}
 
-	node.Changed = lastMod(file)
+	node.Changed = lastMod(file).UTC()
 
 	return node, nil
 }
@@ -246,6 +270,23 @@ func assertID(id string) error {
 	return nil
 }
 
+// assertTitle returns an error if the title is empty, longer than 70
+// runes, or contains anything but unicode.IsPrint runes.
+func assertTitle(title string) error {
+	if title == "" {
+		return fmt.Errorf(_EmptyTitle)
+	}
+	if n := len([]rune(title)); n > 70 {
+		return fmt.Errorf(_TitleTooLong, n)
+	}
+	for _, r := range title {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf(_TitleInvalid, r)
+		}
+	}
+	return nil
+}
+
 // Validate returns one error for every one of the following possible
 // failed assertions:
 //
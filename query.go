@@ -0,0 +1,439 @@
+package keg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query returns the nodes of the Index matching the query expression
+// (see below) in the order of Nodes unless ordered. An empty expression
+// matches every node. The Index itself is never changed. Expressions
+// combine predicates (field, operator, value without spaces between)
+// with and, or, not, and parenthesis (and binds tighter than or, and is
+// implied between predicates) optionally followed by order by (id,
+// title, or changed, then asc or desc) and limit:
+//
+//     title~"^Sample"          title matches regular expression
+//     title:node               title contains (ignoring case)
+//     title="Exact Title"      title is exactly
+//     changed>=2022-11-01      also > < <= = : and != (days or full times)
+//     includes:5               includes node 5
+//     includedby:1             included by node 1
+//     id:10..20                also id:10.. id:..20 id:5 id>=10 and such
+//
+//     title~"(?i)keg" and not includedby:0 order by changed desc limit 10
+//
+// Values containing spaces or parenthesis must be quoted (with Go
+// string syntax). Dates without a time cover the whole day (UTC) so
+// that changed:2022-11-01 matches any time that day.
+func (dex *Index) Query(expr string) ([]*Node, error) {
+	q := &query{dex: dex}
+	if err := q.lex(expr); err != nil {
+		return nil, err
+	}
+	match, err := q.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*Node{}
+	for _, n := range dex.Nodes {
+		if match == nil || match(n) {
+			nodes = append(nodes, n)
+		}
+	}
+
+	if q.order != nil {
+		sort.SliceStable(nodes, func(i, j int) bool { return q.order(nodes[i], nodes[j]) })
+	}
+	if q.limit >= 0 && q.limit < len(nodes) {
+		nodes = nodes[:q.limit]
+	}
+	return nodes, nil
+}
+
+// predicate returns true if the Node matches.
+type predicate func(n *Node) bool
+
+// qtoken is a single query token: a word (keyword or number), a left or
+// right parenthesis, or a predicate (field, op, and value).
+type qtoken struct {
+	pos   int
+	word  string
+	field string
+	op    string
+	value string
+}
+
+type query struct {
+	dex   *Index
+	toks  []qtoken
+	i     int
+	order func(a, b *Node) bool
+	limit int
+}
+
+var queryOps = []string{`>=`, `<=`, `!=`, `~`, `=`, `>`, `<`, `:`}
+
+// lex divides the expression into tokens.
+func (q *query) lex(expr string) error {
+	r := []rune(expr)
+	for i := 0; i < len(r); {
+		switch {
+		case unicode.IsSpace(r[i]):
+			i++
+			continue
+		case r[i] == '(' || r[i] == ')':
+			q.toks = append(q.toks, qtoken{pos: i, word: string(r[i])})
+			i++
+			continue
+		}
+
+		beg := i
+		for i < len(r) && !unicode.IsSpace(r[i]) && r[i] != '(' && r[i] != ')' &&
+			!strings.ContainsRune(`~=!<>:`, r[i]) {
+			i++
+		}
+		tok := qtoken{pos: beg, word: string(r[beg:i])}
+		rest := string(r[i:])
+		for _, op := range queryOps {
+			if strings.HasPrefix(rest, op) {
+				tok.field, tok.op, tok.word = strings.ToLower(tok.word), op, ``
+				i += len([]rune(op))
+				break
+			}
+		}
+		if tok.op == `` {
+			if tok.word == `` {
+				return fmt.Errorf(_QueryUnexpected, string(r[i]), i)
+			}
+			q.toks = append(q.toks, tok)
+			continue
+		}
+
+		// value
+		if i < len(r) && r[i] == '"' {
+			end := i + 1
+			for end < len(r) && r[end] != '"' {
+				if r[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(r) {
+				return fmt.Errorf(_QueryUnclosed, i)
+			}
+			v, err := strconv.Unquote(string(r[i : end+1]))
+			if err != nil {
+				return fmt.Errorf(_QueryValue, tok.field, string(r[i:end+1]))
+			}
+			tok.value = v
+			i = end + 1
+		} else {
+			vb := i
+			for i < len(r) && !unicode.IsSpace(r[i]) && r[i] != '(' && r[i] != ')' {
+				i++
+			}
+			tok.value = string(r[vb:i])
+		}
+		q.toks = append(q.toks, tok)
+	}
+	return nil
+}
+
+// peek returns the current token (with empty word and op at the end).
+func (q *query) peek() qtoken {
+	if q.i < len(q.toks) {
+		return q.toks[q.i]
+	}
+	return qtoken{pos: -1}
+}
+
+// keyword returns true (and advances) if the current token is the word.
+func (q *query) keyword(word string) bool {
+	t := q.peek()
+	if t.op == `` && strings.EqualFold(t.word, word) {
+		q.i++
+		return true
+	}
+	return false
+}
+
+func (q *query) unexpected() error {
+	t := q.peek()
+	if t.pos < 0 {
+		return fmt.Errorf(_QueryEnd)
+	}
+	text := t.word
+	if t.op != `` {
+		text = t.field + t.op + t.value
+	}
+	return fmt.Errorf(_QueryUnexpected, text, t.pos)
+}
+
+// parse parses the tokens into a predicate (nil if none), order, and
+// limit.
+func (q *query) parse() (predicate, error) {
+	q.limit = -1
+	var match predicate
+	if t := q.peek(); t.pos >= 0 && !strings.EqualFold(t.word, `order`) &&
+		!strings.EqualFold(t.word, `limit`) {
+		var err error
+		if match, err = q.or(); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.keyword(`order`) {
+		if !q.keyword(`by`) {
+			return nil, q.unexpected()
+		}
+		if err := q.orderBy(); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.keyword(`limit`) {
+		t := q.peek()
+		n, err := strconv.Atoi(t.word)
+		if t.op != `` || err != nil || n < 0 {
+			return nil, q.unexpected()
+		}
+		q.limit = n
+		q.i++
+	}
+
+	if q.i < len(q.toks) {
+		return nil, q.unexpected()
+	}
+	return match, nil
+}
+
+func (q *query) or() (predicate, error) {
+	left, err := q.and()
+	if err != nil {
+		return nil, err
+	}
+	for q.keyword(`or`) {
+		right, err := q.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(n *Node) bool { return l(n) || right(n) }
+	}
+	return left, nil
+}
+
+func (q *query) and() (predicate, error) {
+	left, err := q.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := q.peek()
+		switch {
+		case q.keyword(`and`):
+		case t.op != `` || t.word == `(` || strings.EqualFold(t.word, `not`):
+		default:
+			return left, nil
+		}
+		right, err := q.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(n *Node) bool { return l(n) && right(n) }
+	}
+}
+
+func (q *query) not() (predicate, error) {
+	if q.keyword(`not`) {
+		p, err := q.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(n *Node) bool { return !p(n) }, nil
+	}
+	if q.keyword(`(`) {
+		p, err := q.or()
+		if err != nil {
+			return nil, err
+		}
+		if !q.keyword(`)`) {
+			return nil, q.unexpected()
+		}
+		return p, nil
+	}
+	t := q.peek()
+	if t.op == `` {
+		return nil, q.unexpected()
+	}
+	q.i++
+	return q.predicate(t)
+}
+
+// predicate returns the predicate for a single field, op, and value.
+func (q *query) predicate(t qtoken) (predicate, error) {
+	bad := fmt.Errorf(_QueryValue, t.field, t.value)
+
+	switch t.field {
+
+	case `title`:
+		switch t.op {
+		case `~`:
+			re, err := regexp.Compile(t.value)
+			if err != nil {
+				return nil, fmt.Errorf(_QueryValue, t.field, err)
+			}
+			return func(n *Node) bool { return re.MatchString(n.Title) }, nil
+		case `:`:
+			v := strings.ToLower(t.value)
+			return func(n *Node) bool { return strings.Contains(strings.ToLower(n.Title), v) }, nil
+		case `=`:
+			return func(n *Node) bool { return n.Title == t.value }, nil
+		case `!=`:
+			return func(n *Node) bool { return n.Title != t.value }, nil
+		}
+
+	case `changed`:
+		lo, hi, ok := queryTime(t.value)
+		if !ok {
+			return nil, bad
+		}
+		return compare(t.op, func(n *Node) (bool, bool) {
+			return !n.Changed.Before(lo), !n.Changed.Before(hi)
+		})
+
+	case `id`:
+		if t.op == `:` && strings.Contains(t.value, `..`) {
+			a, b, _ := strings.Cut(t.value, `..`)
+			lo, lerr := strconv.Atoi(a)
+			hi, herr := strconv.Atoi(b)
+			if a == `` {
+				lo, lerr = 0, nil
+			}
+			if b == `` {
+				hi, herr = int(^uint(0)>>1), nil
+			}
+			if lerr != nil || herr != nil {
+				return nil, bad
+			}
+			return func(n *Node) bool {
+				id, err := strconv.Atoi(n.ID)
+				return err == nil && id >= lo && id <= hi
+			}, nil
+		}
+		v, err := strconv.Atoi(t.value)
+		if err != nil {
+			return nil, bad
+		}
+		return compare(t.op, func(n *Node) (bool, bool) {
+			id, err := strconv.Atoi(n.ID)
+			return err == nil && id >= v, err == nil && id > v
+		})
+
+	case `includes`:
+		if t.op != `:` {
+			break
+		}
+		return func(n *Node) bool {
+			for _, i := range n.Includes {
+				if i == t.value {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case `includedby`:
+		if t.op != `:` {
+			break
+		}
+		by := q.dex.idMap()[t.value]
+		return func(n *Node) bool {
+			if by == nil {
+				return false
+			}
+			for _, i := range by.Includes {
+				if i == n.ID {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	default:
+		return nil, fmt.Errorf(_QueryField, t.field)
+
+	}
+
+	return nil, fmt.Errorf(_QueryOp, t.op, t.field)
+}
+
+// compare returns a predicate for the comparison op from cmp which
+// returns whether the node is at or after the low end of the value and
+// whether it is at or after the high end (non-inclusive).
+func compare(op string, cmp func(n *Node) (lo, hi bool)) (predicate, error) {
+	switch op {
+	case `>=`:
+		return func(n *Node) bool { lo, _ := cmp(n); return lo }, nil
+	case `>`:
+		return func(n *Node) bool { _, hi := cmp(n); return hi }, nil
+	case `<`:
+		return func(n *Node) bool { lo, _ := cmp(n); return !lo }, nil
+	case `<=`:
+		return func(n *Node) bool { _, hi := cmp(n); return !hi }, nil
+	case `=`, `:`:
+		return func(n *Node) bool { lo, hi := cmp(n); return lo && !hi }, nil
+	case `!=`:
+		return func(n *Node) bool { lo, hi := cmp(n); return !lo || hi }, nil
+	}
+	return nil, fmt.Errorf(_QueryOp, op, `comparison`)
+}
+
+// queryTime returns the range of time (lo inclusive, hi not) of a date
+// (a whole day) or full time (a second) value.
+func queryTime(v string) (lo, hi time.Time, ok bool) {
+	if t, err := time.Parse(`2006-01-02`, v); err == nil {
+		return t, t.AddDate(0, 0, 1), true
+	}
+	if t, err := time.Parse(IsoTimeLayout, v); err == nil {
+		return t, t.Add(time.Second), true
+	}
+	return lo, hi, false
+}
+
+// orderBy parses the field (and direction) to order by.
+func (q *query) orderBy() error {
+	t := q.peek()
+	var less func(a, b *Node) bool
+	switch strings.ToLower(t.word) {
+	case `id`:
+		less = func(a, b *Node) bool {
+			x, _ := strconv.Atoi(a.ID)
+			y, _ := strconv.Atoi(b.ID)
+			return x < y
+		}
+	case `title`:
+		less = func(a, b *Node) bool { return a.Title < b.Title }
+	case `changed`:
+		less = func(a, b *Node) bool { return a.Changed.Before(b.Changed) }
+	default:
+		return q.unexpected()
+	}
+	q.i++
+	switch {
+	case q.keyword(`desc`):
+		q.order = func(a, b *Node) bool { return less(b, a) }
+	default:
+		q.keyword(`asc`)
+		q.order = less
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package keg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RemoteClient is the http.Client used for every network call unless
+// another is set (see Remote). Unlike http.DefaultClient it has
+// a timeout so that a slow host never hangs forever.
+var RemoteClient = &http.Client{Timeout: 30 * time.Second}
+
+// Remote is a keg published at a URL (see serve) from which the index,
+// info, and node files can be fetched. Every method takes
+// a context.Context for cancellation and deadlines and returns an
+// ErrFetch for any response without a 2xx status. With a Cache every
+// file is kept locally and only downloaded again when changed, and when
+// the keg cannot be reached the cached data is returned along with an
+// ErrStale.
+type Remote struct {
+	URL    string       // base URL of the keg (without trailing slash)
+	Client *http.Client // RemoteClient if nil
+	Cache  *Cache       // optional, see Cache
+}
+
+// NewRemote returns a new Remote for the keg at kegurl. The http.Client
+// used is the Client of the Cache (if set) or else Client or else
+// RemoteClient.
+func NewRemote(kegurl string) *Remote {
+	return &Remote{URL: strings.TrimRight(kegurl, `/`)}
+}
+
+// get fetches the path (already escaped) relative to the URL.
+// With a Cache an ErrStale is returned along with stale data.
+func (r *Remote) get(ctx context.Context, path string) ([]byte, error) {
+	url := strings.TrimRight(r.URL, `/`) + `/` + path
+	if r.Cache == nil {
+		return fetchContext(ctx, r.Client, url)
+	}
+	client := r.Cache.Client
+	if client == nil {
+		client = r.Client
+	}
+	cached, err := r.Cache.fetch(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	if cached.Stale {
+		return cached.Body, ErrStale{url, cached.Checked}
+	}
+	return cached.Body, nil
+}
+
+// usable returns true if err is nil or an ErrStale (meaning there is
+// still data to use).
+func usable(err error) bool {
+	var stale ErrStale
+	return err == nil || errors.As(err, &stale)
+}
+
+// Index fetches and parses the index file (see ParseIndex) setting the
+// URL of the Index to that of the file.
+func (r *Remote) Index(ctx context.Context) (*Index, error) {
+	buf, stale := r.get(ctx, IndexFileName)
+	if !usable(stale) {
+		return nil, stale
+	}
+	dex, err := ParseIndex(buf)
+	if err != nil {
+		return nil, err
+	}
+	dex.URL = strings.TrimRight(r.URL, `/`) + `/` + IndexFileName
+	return dex, stale
+}
+
+// Info fetches and parses the keg info file (see ParseKegInfo).
+func (r *Remote) Info(ctx context.Context) (*KegInfo, error) {
+	buf, stale := r.get(ctx, InfoFileName)
+	if !usable(stale) {
+		return nil, stale
+	}
+	info, err := ParseKegInfo(buf)
+	if err != nil {
+		return nil, err
+	}
+	return info, stale
+}
+
+// Node fetches the README.md of the node with the given ID.
+func (r *Remote) Node(ctx context.Context, id string) ([]byte, error) {
+	return r.File(ctx, id, `README.md`)
+}
+
+// File fetches the file with the given name from the directory of the
+// node with the given ID. The name may not contain a slash.
+func (r *Remote) File(ctx context.Context, id, name string) ([]byte, error) {
+	if err := assertID(id); err != nil {
+		return nil, err
+	}
+	if name == `` || name == `.` || name == `..` || strings.Contains(name, `/`) {
+		return nil, fmt.Errorf(_InvalidFileName, name)
+	}
+	return r.get(ctx, id+`/`+url.PathEscape(name))
+}
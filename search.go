@@ -0,0 +1,351 @@
+package keg
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rwxrob/keg/kegml"
+)
+
+// SearchFileName is the name of the file within the DexDirName
+// directory containing the persisted SearchIndex (see WriteSearch).
+const SearchFileName = `search.json`
+
+// BM25 parameters used to rank search results (see SearchIndex.Search).
+var (
+	SearchK1 = 1.2  // term frequency saturation
+	SearchB  = 0.75 // document length normalization
+)
+
+// SearchIndex is a full-text inverted index of the content of nodes.
+// Every term (see SearchTerms) is mapped to the IDs of the nodes that
+// contain it and the positions (term offsets) within each at which it
+// occurs. The number of positions is the term frequency and the
+// positions themselves allow phrase queries. Lengths contains the
+// total number of terms of every node indexed.
+type SearchIndex struct {
+	Lengths map[string]int              `json:"lengths"`
+	Terms   map[string]map[string][]int `json:"terms"`
+}
+
+// SearchResult is a single node matching a query with its rank.
+type SearchResult struct {
+	ID    string
+	Score float64
+}
+
+// NewSearchIndex returns a new empty SearchIndex.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		Lengths: map[string]int{},
+		Terms:   map[string]map[string][]int{},
+	}
+}
+
+// SearchTerms returns the terms of the text in the order they occur.
+// Terms are sequences of Unicode letters and numbers (everything else
+// separates them) folded to lower case.
+func SearchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Add indexes the KEGML content (see kegml.PlainText for input types)
+// of the node with the given ID replacing anything already indexed for
+// it. Only the text is indexed, never the markup.
+func (s *SearchIndex) Add(id string, in any) {
+	s.Remove(id)
+	s.add(id, SearchTerms(kegml.PlainText(in)))
+}
+
+// add indexes the terms of a node not yet indexed.
+func (s *SearchIndex) add(id string, terms []string) {
+	for i, t := range terms {
+		if s.Terms[t] == nil {
+			s.Terms[t] = map[string][]int{}
+		}
+		s.Terms[t][id] = append(s.Terms[t][id], i)
+	}
+	s.Lengths[id] = len(terms)
+}
+
+// Remove removes everything indexed for the node with the given ID.
+func (s *SearchIndex) Remove(id string) {
+	if _, has := s.Lengths[id]; !has {
+		return
+	}
+	for t, ids := range s.Terms {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(s.Terms, t)
+		}
+	}
+	delete(s.Lengths, id)
+}
+
+// ScanSearch returns a new SearchIndex of the README.md of every
+// content node directory (see NodeDirs) within kegpath read and split
+// into terms concurrently (see ScanWorkers). Nodes that fail to be read
+// are reported in an ErrScan but the index is still returned without
+// them.
+func ScanSearch(kegpath string) (*SearchIndex, error) {
+	s := NewSearchIndex()
+	dirs, _, _, err := nodeDirs(kegpath)
+	if err != nil {
+		return s, err
+	}
+	terms := make([][]string, len(dirs))
+	failed := scanDirs(dirs, func(i int) error {
+		buf, err := os.ReadFile(filepath.Join(dirs[i], `README.md`))
+		if err == nil {
+			terms[i] = SearchTerms(kegml.PlainText(buf))
+		}
+		return err
+	})
+	for i, dir := range dirs {
+		id := filepath.Base(dir)
+		if _, has := failed[id]; !has {
+			s.add(id, terms[i])
+		}
+	}
+	if len(failed) > 0 {
+		return s, failed
+	}
+	return s, nil
+}
+
+// WriteSearch writes the SearchIndex as JSON to the SearchFileName file
+// within the DexDirName directory of kegpath (creating it if needed)
+// replacing it atomically.
+func WriteSearch(kegpath string, s *SearchIndex) error {
+	dir := filepath.Join(kegpath, DexDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, SearchFileName), buf)
+}
+
+// searchCurrent returns true if the search index file of the keg at
+// kegpath was written after the keg directory (where nodes are added
+// and removed), the index file, and the README.md of every content node
+// directory (see NodeDirs) were last changed. Those that are missing
+// are ignored.
+func searchCurrent(kegpath string) bool {
+	search, err := os.Stat(filepath.Join(kegpath, DexDirName, SearchFileName))
+	if err != nil {
+		return false
+	}
+	dirs, _, _, _ := nodeDirs(kegpath)
+	files := []string{kegpath, filepath.Join(kegpath, IndexFileName)}
+	for _, dir := range dirs {
+		files = append(files, filepath.Join(dir, `README.md`))
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err == nil && !search.ModTime().After(info.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadSearch reads the SearchIndex written by WriteSearch from kegpath.
+func ReadSearch(kegpath string) (*SearchIndex, error) {
+	buf, err := os.ReadFile(filepath.Join(kegpath, DexDirName, SearchFileName))
+	if err != nil {
+		return nil, err
+	}
+	s := NewSearchIndex()
+	if err := json.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// searchPart is a single part of a query (see Search): one or more
+// terms that must occur together (in order) or a term prefix.
+type searchPart struct {
+	terms  []string
+	prefix bool
+}
+
+// parseQuery divides the query into parts.
+func parseQuery(query string) ([]searchPart, error) {
+	var parts []searchPart
+	for query = strings.TrimSpace(query); query != ""; query = strings.TrimSpace(query) {
+		var word string
+		if query[0] == '"' {
+			end := strings.IndexByte(query[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf(_SearchQuote, query)
+			}
+			word, query = query[1:end+1], query[end+2:]
+		} else {
+			word, query, _ = strings.Cut(query, ` `)
+		}
+		p := searchPart{terms: SearchTerms(word)}
+		if len(p.terms) == 0 {
+			continue
+		}
+		p.prefix = len(p.terms) == 1 && strings.HasSuffix(word, `*`)
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// Search returns the nodes matching every part of the query ranked by
+// relevance (Okapi BM25, see SearchK1 and SearchB) from most to least
+// relevant (and then by ID). Each part of the query is one of the
+// following:
+//
+//     word        - must contain the term (see SearchTerms)
+//     word*       - must contain a term beginning with word
+//     "a phrase"  - must contain the terms in the same order together
+//
+// Words that contain more than one term (ex: foo-bar) are phrases. An
+// error is only returned if a phrase is never closed. Always returns
+// a slice even if empty.
+func (s *SearchIndex) Search(query string) ([]SearchResult, error) {
+	results := []SearchResult{}
+	parts, err := parseQuery(query)
+	if err != nil || len(parts) == 0 || len(s.Lengths) == 0 {
+		return results, err
+	}
+
+	var total int
+	for _, l := range s.Lengths {
+		total += l
+	}
+	n := float64(len(s.Lengths))
+	avg := float64(total) / n
+
+	bm25 := func(term, id string) float64 {
+		docs := s.Terms[term]
+		tf := float64(len(docs[id]))
+		if tf == 0 {
+			return 0
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		norm := 1 - SearchB + SearchB*float64(s.Lengths[id])/avg
+		return idf * tf * (SearchK1 + 1) / (tf + SearchK1*norm)
+	}
+
+	var scores map[string]float64
+	for _, p := range parts {
+		found := map[string]float64{}
+		if p.prefix {
+			for _, t := range s.prefixed(p.terms[0]) {
+				for id := range s.Terms[t] {
+					found[id] += bm25(t, id)
+				}
+			}
+		} else {
+			for id := range s.Terms[p.terms[0]] {
+				if !s.phrase(id, p.terms) {
+					continue
+				}
+				for _, t := range p.terms {
+					found[id] += bm25(t, id)
+				}
+			}
+		}
+		if scores == nil {
+			scores = found
+			continue
+		}
+		for id := range scores {
+			if _, has := found[id]; !has {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += found[id]
+		}
+	}
+
+	for id, score := range scores {
+		results = append(results, SearchResult{id, score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		a, _ := strconv.Atoi(results[i].ID)
+		b, _ := strconv.Atoi(results[j].ID)
+		return a < b
+	})
+	return results, nil
+}
+
+// prefixed returns every indexed term beginning with prefix.
+func (s *SearchIndex) prefixed(prefix string) []string {
+	var terms []string
+	for t := range s.Terms {
+		if strings.HasPrefix(t, prefix) {
+			terms = append(terms, t)
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// phrase returns true if the node contains the terms in order together.
+func (s *SearchIndex) phrase(id string, terms []string) bool {
+	if len(terms) == 1 {
+		return true
+	}
+	for _, at := range s.Terms[terms[0]][id] {
+		match := true
+		for i, t := range terms[1:] {
+			pos := s.Terms[t][id]
+			j := sort.SearchInts(pos, at+i+1)
+			if j == len(pos) || pos[j] != at+i+1 {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Search searches the persisted search index of the keg (see
+// ReadSearch) creating and writing a new one first (see ScanSearch and
+// WriteSearch) if there is none or any node or the index file has
+// changed since it was written. Nodes that could not be read are
+// reported in an ErrScan returned along with the results. See
+// SearchIndex.Search for the query syntax.
+func (k *Keg) Search(query string) ([]SearchResult, error) {
+	s, err := ReadSearch(k.Path)
+	switch {
+	case os.IsNotExist(err) || err == nil && !searchCurrent(k.Path):
+		s, err = ScanSearch(k.Path)
+		if _, ok := err.(ErrScan); err != nil && !ok {
+			return nil, err
+		}
+		if werr := WriteSearch(k.Path, s); werr != nil {
+			return nil, werr
+		}
+	case err != nil:
+		return nil, err
+	}
+	results, serr := s.Search(query)
+	if serr != nil {
+		return nil, serr
+	}
+	return results, err
+}
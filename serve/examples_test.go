@@ -0,0 +1,118 @@
+package serve_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rwxrob/keg/serve"
+)
+
+func ExampleHandler() {
+
+	h, err := serve.New(`../testdata/samplekeg`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	get := func(method, path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	show := func(method, path string, header ...string) *httptest.ResponseRecorder {
+		w := get(method, path, header...)
+		fmt.Println(strings.TrimSpace(fmt.Sprint(method, " ", path, " ", w.Code, " ", w.Header().Get(`Content-Type`))))
+		return w
+	}
+
+	w := show(`GET`, `/kegdex`)
+	fmt.Println(strings.SplitN(w.Body.String(), "\n", 2)[0])
+	info, _ := os.Stat(`../testdata/samplekeg/kegdex`)
+	fmt.Println(w.Header().Get(`Last-Modified`) == info.ModTime().UTC().Format(http.TimeFormat))
+
+	etag := show(`GET`, `/1/README.md`).Header().Get(`ETag`)
+	show(`GET`, `/1/README.md`, `If-None-Match`, etag)
+	show(`GET`, `/1/README.md`, `If-Modified-Since`, `Sat, 26 Nov 2022 19:33:24 GMT`)
+	show(`GET`, `/1/README.md`, `If-Modified-Since`, `Sat, 26 Nov 2022 19:33:23 GMT`)
+
+	w = show(`GET`, `/1/`)
+	body := w.Body.String()
+	fmt.Println(strings.Contains(body, `<title>Sample content node</title>`),
+		strings.Contains(body, `<h1>Sample content node</h1>`))
+
+	fmt.Println(show(`GET`, `/1`).Header().Get(`Location`))
+	show(`HEAD`, `/keg`)
+	show(`GET`, `/dex/changes.md`)
+	show(`GET`, `/`)
+	show(`GET`, `/99/`)
+	show(`GET`, `/1/nope.png`)
+	show(`GET`, `/1/../../../etc/passwd`)
+	show(`POST`, `/kegdex`)
+
+	// Output:
+	// GET /kegdex 200 text/plain; charset=utf-8
+	// 0	2022-11-22 18:05:51Z	Sorry, planned but not yet available
+	// true
+	// GET /1/README.md 200 text/markdown; charset=utf-8
+	// GET /1/README.md 304
+	// GET /1/README.md 304
+	// GET /1/README.md 200 text/markdown; charset=utf-8
+	// GET /1/ 200 text/html; charset=utf-8
+	// true true
+	// GET /1 301 text/html; charset=utf-8
+	// /1/
+	// HEAD /keg 200 text/yaml; charset=utf-8
+	// GET /dex/changes.md 200 text/markdown; charset=utf-8
+	// GET / 200 text/html; charset=utf-8
+	// GET /99/ 404 text/plain; charset=utf-8
+	// GET /1/nope.png 404 text/plain; charset=utf-8
+	// GET /1/../../../etc/passwd 404 text/plain; charset=utf-8
+	// POST /kegdex 405 text/plain; charset=utf-8
+}
+
+func ExampleHandler_removed() {
+
+	dir, _ := os.MkdirTemp("", "keg")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, "keg"), []byte("updated: 2022-11-26 19:33:24Z\n"), 0600)
+	for _, id := range []string{"1", "2"} {
+		os.Mkdir(filepath.Join(dir, id), 0700)
+		os.WriteFile(filepath.Join(dir, id, "README.md"), []byte("# Node "+id+"\n"), 0600)
+	}
+	dex := filepath.Join(dir, "kegdex")
+	before := time.Date(2022, 11, 26, 19, 33, 24, 0, time.UTC)
+	os.WriteFile(dex, []byte("1\t2022-11-01 00:00:00Z\tNode 1\n2\t2022-11-02 00:00:00Z\tNode 2\n"), 0600)
+	os.Chtimes(filepath.Join(dir, "keg"), before, before)
+	os.Chtimes(dex, before, before)
+
+	h, _ := serve.New(dir)
+	get := func(path string) int {
+		req := httptest.NewRequest(`GET`, path, nil)
+		req.Header.Set(`If-Modified-Since`, before.Format(http.TimeFormat))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	fmt.Println(get(`/kegdex`), get(`/`))
+
+	// removing the newest node changes the index but no Changed time
+	later := before.Add(time.Hour)
+	os.WriteFile(dex, []byte("1\t2022-11-01 00:00:00Z\tNode 1\n"), 0600)
+	os.Chtimes(dex, later, later)
+	fmt.Println(get(`/kegdex`), get(`/`))
+
+	// Output:
+	// 304 304
+	// 200 200
+}
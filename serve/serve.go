@@ -0,0 +1,303 @@
+/*
+Package serve publishes a keg directory over HTTP as a KEG site that
+can be read by people (rendered HTML) and by other keg tools (see
+keg.FetchIndex) alike:
+
+    /                 HTML list of nodes by latest change
+    /kegdex           index file (keg.IndexFileName)
+    /keg              keg info file (keg.InfoFileName)
+    /dex/*            dex directory files (changes.md, nodes.tsv, ...)
+    /N/               node N rendered as HTML (see kegml/html)
+    /N/README.md      node N KEGML source
+    /N/*              node N files (images, data, ...)
+
+Every response has its content type, an ETag, and a Last-Modified
+header so that clients can cache and revalidate (If-None-Match and
+If-Modified-Since). Node ETags are derived from the Changed time of the
+node in the index so they change whenever the index does.
+*/
+package serve
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rwxrob/keg"
+	"github.com/rwxrob/keg/kegml"
+	"github.com/rwxrob/keg/kegml/ast"
+	"github.com/rwxrob/keg/kegml/html"
+)
+
+// ContentTypes maps file extensions to the content type used for them
+// (before falling back to mime.TypeByExtension and sniffing).
+var ContentTypes = map[string]string{
+	`.md`:   `text/markdown; charset=utf-8`,
+	`.tsv`:  `text/tab-separated-values; charset=utf-8`,
+	`.json`: `application/json`,
+}
+
+// Page is the template used for every rendered HTML page. It is passed
+// the Title and Body (already rendered HTML) and loads MathJax so that
+// math renders.
+var Page = template.Must(template.New(`page`).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>.lede{font-size:1.2em}</style>
+<script id="MathJax-script" async src="https://cdn.jsdelivr.net/npm/mathjax@3/es5/tex-mml-chtml.js"></script>
+</head>
+<body>
+<main>
+{{.Body}}
+</main>
+</body>
+</html>
+`))
+
+var nodePath = regexp.MustCompile(`^/(\d+)(/.*)?$`)
+
+// Handler is an http.Handler serving a single keg directory. Use New
+// to create one. The index is reread whenever the index file changes.
+type Handler struct {
+	Path     string         // absolute path to keg directory
+	Renderer *html.Renderer // used to render nodes (see New)
+
+	mu     sync.Mutex
+	keg    *keg.Keg
+	loaded time.Time // modification time of index file when opened
+}
+
+// New returns a Handler for the keg directory at kegpath (see
+// keg.OpenKeg) with a Renderer that links nodes to their rendered
+// pages (../N/). As with keg.OpenKeg, nodes that could not be read are
+// reported in a keg.ErrScan returned along with the usable Handler.
+func New(kegpath string) (*Handler, error) {
+	k, err := keg.OpenKeg(kegpath)
+	if k == nil {
+		return nil, err
+	}
+	h := &Handler{Path: k.Path, keg: k, loaded: modTime(indexFile(k.Path))}
+	h.Renderer = &html.Renderer{
+		NodeURL: func(id string) string { return `../` + id + `/` },
+	}
+	return h, err
+}
+
+// indexFile returns the path to the index file of the keg at kegpath.
+func indexFile(kegpath string) string { return filepath.Join(kegpath, keg.IndexFileName) }
+
+// modTime returns the modification time of the file or the zero time.
+func modTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// current returns the Keg reopening it first if the index file has
+// changed since last opened.
+func (h *Handler) current() *keg.Keg {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if mod := modTime(indexFile(h.Path)); !mod.Equal(h.loaded) {
+		if k, _ := keg.OpenKeg(h.Path); k != nil {
+			h.keg, h.loaded = k, mod
+		}
+	}
+	return h.keg
+}
+
+// ServeHTTP fulfills the http.Handler interface. Only GET and HEAD are
+// allowed.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set(`Allow`, `GET, HEAD`)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	k := h.current()
+	p := path.Clean(r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, `/`) && p != `/` {
+		p += `/`
+	}
+
+	switch {
+
+	case p == `/`:
+		h.serveHome(w, r, k)
+
+	case p == `/`+keg.IndexFileName:
+		h.serveFile(w, r, filepath.Join(k.Path, keg.IndexFileName), time.Time{}, `text/plain; charset=utf-8`)
+
+	case p == `/`+keg.InfoFileName:
+		h.serveFile(w, r, filepath.Join(k.Path, keg.InfoFileName), time.Time{}, `text/yaml; charset=utf-8`)
+
+	case strings.HasPrefix(p, `/`+keg.DexDirName+`/`):
+		name := strings.TrimPrefix(p, `/`+keg.DexDirName+`/`)
+		h.serveFile(w, r, filepath.Join(k.Dex, filepath.FromSlash(name)), time.Time{}, ``)
+
+	case nodePath.MatchString(p):
+		m := nodePath.FindStringSubmatch(p)
+		h.serveNode(w, r, k, m[1], m[2])
+
+	default:
+		http.NotFound(w, r)
+
+	}
+}
+
+// serveNode serves the rendered node (/N/), its source (/N/README.md),
+// or one of its files (/N/name) redirecting /N to /N/.
+func (h *Handler) serveNode(w http.ResponseWriter, r *http.Request, k *keg.Keg, id, rest string) {
+	dir := k.NodePath(id)
+	if !keg.DirIsNode(dir) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var changed time.Time
+	if n := k.Node(id); n != nil {
+		changed = n.Changed
+	}
+
+	switch rest {
+
+	case ``:
+		http.Redirect(w, r, `/`+id+`/`, http.StatusMovedPermanently)
+
+	case `/`:
+		buf, err := k.Body(id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		doc, _ := kegml.Parse(buf)
+		title := id
+		if n := k.Node(id); n != nil {
+			title = n.Title
+		} else if len(doc.Under) > 0 && doc.Under[0].T == ast.Title {
+			title = doc.Under[0].V
+		}
+		page, err := h.page(title, doc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if changed.IsZero() {
+			changed = modTime(filepath.Join(dir, `README.md`))
+		}
+		w.Header().Set(`Content-Type`, `text/html; charset=utf-8`)
+		w.Header().Set(`ETag`, etag(id+`-html`, changed))
+		http.ServeContent(w, r, ``, changed, bytes.NewReader(page))
+
+	case `/README.md`:
+		h.serveFile(w, r, filepath.Join(dir, `README.md`), changed, ``)
+
+	default:
+		h.serveFile(w, r, filepath.Join(dir, filepath.FromSlash(rest[1:])), time.Time{}, ``)
+
+	}
+}
+
+// serveHome serves a rendered list of every node by latest change
+// titled with the keg title (or keg.ChangesTitle). Since it is made from
+// the index and info files it changes whenever either of them does
+// (including when nodes are removed).
+func (h *Handler) serveHome(w http.ResponseWriter, r *http.Request, k *keg.Keg) {
+	title := k.Info.Title
+	if title == `` {
+		title = keg.ChangesTitle
+	}
+	var changes bytes.Buffer
+	changes.WriteString(`# ` + title + "\n\n")
+	k.Index.WriteChanges(&changes)
+	doc, _ := kegml.Parse(changes.String())
+	page, err := h.page(title, doc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	mod := modTime(indexFile(k.Path))
+	if info := modTime(filepath.Join(k.Path, keg.InfoFileName)); info.After(mod) {
+		mod = info
+	}
+	w.Header().Set(`Content-Type`, `text/html; charset=utf-8`)
+	if !mod.IsZero() {
+		w.Header().Set(`ETag`, etag(`home`, mod))
+	}
+	http.ServeContent(w, r, ``, mod, bytes.NewReader(page))
+}
+
+// page returns the rendered HTML page (see Page) of the document.
+func (h *Handler) page(title string, doc *ast.Node) ([]byte, error) {
+	var body, page bytes.Buffer
+	if err := h.Renderer.Render(&body, doc); err != nil {
+		return nil, err
+	}
+	err := Page.Execute(&page, struct {
+		Title string
+		Body  template.HTML
+	}{title, template.HTML(body.String())})
+	return page.Bytes(), err
+}
+
+// serveFile serves the regular file (never a directory or hidden file)
+// with the given content type (or one from ContentTypes or
+// http.ServeContent if empty). The ETag and Last-Modified are derived
+// from changed unless zero in which case the modification time of the
+// file is used instead.
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, file string, changed time.Time, ctype string) {
+	rel, err := filepath.Rel(h.Path, file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), `/`) {
+		if strings.HasPrefix(part, `.`) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if changed.IsZero() {
+		changed = info.ModTime()
+	}
+	if ctype == `` {
+		ctype = ContentTypes[filepath.Ext(file)]
+	}
+	if ctype != `` {
+		w.Header().Set(`Content-Type`, ctype)
+	}
+	w.Header().Set(`ETag`, etag(strconv.FormatInt(info.Size(), 36), changed))
+	http.ServeContent(w, r, info.Name(), changed, f)
+}
+
+// etag returns a strong ETag derived from the name and time.
+func etag(name string, t time.Time) string {
+	return `"` + name + `-` + strconv.FormatInt(t.Unix(), 36) + `"`
+}
@@ -1,9 +1,26 @@
 package keg
 
 const (
-	_Fetch         = "failed to fetch: %v"
-	_InvalidNodeID = `Node identifier must be positive integer`
-	_EmptyTitle    = `Node title is empty`
-	_TitleTooLong  = `Title is too long: %v`
-	_ChangedIsZero = `Node date last changed is not set (zero value)`
+	_Fetch            = "failed to fetch: %v"
+	_Scan             = "failed to read %v node(s): %v"
+	_ScanNode         = "%v: %v"
+	_InfoUpdatedFirst = `updated must be the first line of keg info`
+	_InfoLine         = `invalid keg info line %v: %q`
+	_InvalidNodeID    = `Node identifier must be positive integer`
+	_EmptyTitle       = `Node title is empty`
+	_TitleTooLong     = `Title is too long: %v`
+	_TitleInvalid     = `Title contains invalid rune: %q`
+	_ChangedIsZero    = `Node date last changed is not set (zero value)`
+	_Cycle            = `include cycle: %v`
+	_IncludeCode      = `unknown include query code %q in node %v`
+	_InvalidFileName  = `invalid node file name: %q`
+	_NotCached        = `not cached: %v`
+	_Stale            = `stale copy of %v (last checked %v)`
+	_SearchQuote      = `unclosed phrase in query: %v`
+	_QueryUnexpected  = `query: unexpected %q at %v`
+	_QueryUnclosed    = `query: unclosed quote at %v`
+	_QueryEnd         = `query: unexpected end`
+	_QueryField       = `query: unknown field %q`
+	_QueryOp          = `query: invalid operator %q for %v`
+	_QueryValue       = `query: invalid %v value: %v`
 )